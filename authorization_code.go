@@ -1,27 +1,90 @@
 package oauth2
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
 	"strings"
+	"time"
 )
 
+// ErrStateMismatch is returned when the state in the redirect does not match the expected state.
+var ErrStateMismatch = errors.New("mismatching states")
+
+// ErrReservedParam is returned when WithQueryParam is given a parameter the flow sets itself.
+var ErrReservedParam = errors.New("reserved query parameter")
+
+// Prompt is the value of the "prompt" parameter of the consent page.
+type Prompt string
+
+// PromptLogin makes eBay ask the user to sign in, even when they are already signed in.
+const PromptLogin Prompt = "login"
+
+// reservedParams are the query parameters of the consent URL that are set by the flow.
+var reservedParams = map[string]bool{
+	FieldClientID:     true,
+	FieldRedirectURI:  true,
+	FieldResponseType: true,
+	FieldScope:        true,
+	FieldPrompt:       true,
+	FieldState:        true,
+	FieldLocale:       true,
+}
+
+// AuthorizationCodeFlow is the authorization code flow, which gets a user access token once the
+// user grants the application access on the consent page.
+type AuthorizationCodeFlow interface {
+	// Scopes returns the scopes requested in this flow.
+	Scopes() []string
+	// GrantType returns the grant type of this flow. Should always be "authorization_code".
+	GrantType() string
+	// Prompt returns the prompt, if any.
+	Prompt() Prompt
+	// Locale returns the locale, if any.
+	Locale() string
+	// State returns the fixed state token, if any.
+	State() string
+	// GrantApplicationAccessURL returns the url of the consent page.
+	GrantApplicationAccessURL() (*url.URL, error)
+	// ExchangeAuthorizationForToken exchanges the code of the redirect url for the access token.
+	ExchangeAuthorizationForToken(reqURL *url.URL) (*AccessToken, error)
+	// ExchangeAuthorizationForTokenWithContext is like ExchangeAuthorizationForToken, bound to ctx.
+	ExchangeAuthorizationForTokenWithContext(ctx context.Context, reqURL *url.URL) (*AccessToken, error)
+	// BeginAuthorization starts an authorization attempt with its own random state.
+	BeginAuthorization(
+		w http.ResponseWriter,
+		r *http.Request,
+		store StateStore,
+		ttl time.Duration,
+		metadata map[string]string,
+	) (*url.URL, error)
+	// CompleteAuthorization completes an authorization attempt started with BeginAuthorization.
+	CompleteAuthorization(w http.ResponseWriter, r *http.Request, store StateStore) (*AccessToken, *AuthState, error)
+}
+
 type authorizationCodeFlow struct {
 	*oauth2Client
 	scopes    []string
 	state     string
-	prompt    string
+	prompt    Prompt
+	locale    string
+	params    url.Values
+	paramErr  error
 	grantType string
 }
 
-type authorizationCodeOption func(*authorizationCodeFlow)
+// AuthorizationCodeOption configures the authorization code flow, e.g. WithPrompt or WithState.
+type AuthorizationCodeOption func(*authorizationCodeFlow)
 
 // AuthorizationCode takes scopes as an array of strings and options and
-// returns the authorization code flow object.
+// returns the authorization code flow object. Scopes may be given by their
+// short names, like "sell.inventory".
 func (o *oauth2Client) AuthorizationCode(
 	scopes []string,
-	options ...authorizationCodeOption,
-) *authorizationCodeFlow {
+	options ...AuthorizationCodeOption,
+) AuthorizationCodeFlow {
 
 	acf := &authorizationCodeFlow{
 		oauth2Client: o,
@@ -34,14 +97,15 @@ func (o *oauth2Client) AuthorizationCode(
 	return acf
 }
 
-func (a *authorizationCodeFlow) applyOptions(options ...authorizationCodeOption) {
+func (a *authorizationCodeFlow) applyOptions(options ...AuthorizationCodeOption) {
 	for _, opt := range options {
 		opt(a)
 	}
 }
 
-// WithPrompt is used for adding the "prompt" option used in the GrantApplicationAccessURL.
-func WithPrompt(prompt string) authorizationCodeOption {
+// WithPrompt is used for adding the "prompt" option used in the GrantApplicationAccessURL,
+// e.g. PromptLogin.
+func WithPrompt(prompt Prompt) AuthorizationCodeOption {
 	return func(a *authorizationCodeFlow) {
 		a.prompt = prompt
 	}
@@ -49,12 +113,38 @@ func WithPrompt(prompt string) authorizationCodeOption {
 
 // WithState is used for adding the "state" option used for maintaining an optional application
 // state token throughout the authorization code flow.
-func WithState(state string) authorizationCodeOption {
+func WithState(state string) AuthorizationCodeOption {
 	return func(a *authorizationCodeFlow) {
 		a.state = state
 	}
 }
 
+// WithLocale is used for adding the "locale" option used in the GrantApplicationAccessURL, so the
+// consent page is shown in the language of the locale, e.g. "de-DE" or "fr-FR".
+func WithLocale(locale string) AuthorizationCodeOption {
+	return func(a *authorizationCodeFlow) {
+		a.locale = locale
+	}
+}
+
+// WithQueryParam is used for adding a query parameter the flow has no option for to the
+// GrantApplicationAccessURL. Parameters set by the flow, such as "scope" or "state", are
+// reserved: GrantApplicationAccessURL then fails with ErrReservedParam.
+func WithQueryParam(key, value string) AuthorizationCodeOption {
+	return func(a *authorizationCodeFlow) {
+		if reservedParams[key] {
+			a.paramErr = fmt.Errorf("%w %q", ErrReservedParam, key)
+			return
+		}
+
+		if a.params == nil {
+			a.params = url.Values{}
+		}
+
+		a.params.Add(key, value)
+	}
+}
+
 // Scopes returns the scopes used in this authorization code flow.
 func (a *authorizationCodeFlow) Scopes() []string {
 	return a.scopes
@@ -66,10 +156,15 @@ func (a *authorizationCodeFlow) GrantType() string {
 }
 
 // Prompt returns the prompt (if any, if not returns empty string) used in this authorization code flow.
-func (a *authorizationCodeFlow) Prompt() string {
+func (a *authorizationCodeFlow) Prompt() Prompt {
 	return a.prompt
 }
 
+// Locale returns the locale (if any, if not returns empty string) used in this authorization code flow.
+func (a *authorizationCodeFlow) Locale() string {
+	return a.locale
+}
+
 // State returns the state token (if any, if not returns empty string) used in this authorization code flow.
 func (a *authorizationCodeFlow) State() string {
 	return a.state
@@ -79,25 +174,45 @@ func (a *authorizationCodeFlow) State() string {
 // oauth2 authorization code flow, redirecting the user to authorize (or not)
 // your application.
 func (a *authorizationCodeFlow) GrantApplicationAccessURL() (*url.URL, error) {
-	requestUrl, err := url.Parse(a.baseURL)
+	return a.grantApplicationAccessURL(a.state)
+}
+
+// grantApplicationAccessURL builds the url used for starting the flow with the state.
+func (a *authorizationCodeFlow) grantApplicationAccessURL(state string) (*url.URL, error) {
+	if a.paramErr != nil {
+		return nil, a.paramErr
+	}
+
+	requestUrl, err := url.Parse(a.endpoints.AuthorizeURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse url %+v\n", err)
 	}
 
-	requestUrl.Path = AuthorizePath
-
 	qs := url.Values{}
+	for key, values := range a.params {
+		qs[key] = append([]string(nil), values...)
+	}
+
 	qs.Set(FieldClientID, a.clientID)
-	qs.Set(FieldRedirectURI, a.redirectURI)
+	qs.Set(FieldRedirectURI, a.ruName.Name)
 	qs.Set(FieldResponseType, FieldCode)
-	qs.Set(FieldScope, strings.Join(a.scopes, " ")) // a URL-encoded string of space-separated scopes
+	scopes, err := ExpandScopes(a.scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	qs.Set(FieldScope, strings.Join(scopes, " ")) // a URL-encoded string of space-separated scopes
 
 	if a.prompt != "" {
-		qs.Set(FieldPrompt, a.prompt)
+		qs.Set(FieldPrompt, string(a.prompt))
+	}
+
+	if a.locale != "" {
+		qs.Set(FieldLocale, a.locale)
 	}
 
-	if a.state != "" {
-		qs.Set(FieldState, a.state)
+	if state != "" {
+		qs.Set(FieldState, state)
 	}
 
 	requestUrl.RawQuery = qs.Encode()
@@ -107,24 +222,120 @@ func (a *authorizationCodeFlow) GrantApplicationAccessURL() (*url.URL, error) {
 
 // ExchangeAuthorizationForToken takes the url invoked in the GET request to the redirect URI,
 // parses the query out of that url for the "code" and potentially the "state" fields, and exchanges
-// those in a request to the token endpoint for the access token.
+// those in a request to the token endpoint for the access token. When the user declined to grant
+// access, or eBay redirected with an error, it returns an *AuthorizationError, see ParseCallback.
+// When the RuName of the client has an accept URL, the host and path of reqURL must match it.
 func (a *authorizationCodeFlow) ExchangeAuthorizationForToken(reqURL *url.URL) (*AccessToken, error) {
-	code := reqURL.Query().Get(FieldCode)
-	if code == "" {
-		return nil, fmt.Errorf("missing code in query %s\n", reqURL.String())
+	return a.ExchangeAuthorizationForTokenWithContext(context.Background(), reqURL)
+}
+
+// ExchangeAuthorizationForTokenWithContext is like ExchangeAuthorizationForToken, but the request
+// to the token endpoint stops when ctx is cancelled or its deadline expires.
+func (a *authorizationCodeFlow) ExchangeAuthorizationForTokenWithContext(
+	ctx context.Context,
+	reqURL *url.URL,
+) (*AccessToken, error) {
+	callback, err := ParseCallback(reqURL)
+	if err != nil {
+		return nil, err
 	}
 
-	state := reqURL.Query().Get(FieldState)
+	if err := a.ruName.checkCallbackURL(reqURL); err != nil {
+		return nil, err
+	}
+
+	state := callback.State
 	if (state != "" || a.state != "") && state != a.state {
-		return nil, fmt.Errorf("mismatching states, got %s, expected %s", state, a.state)
+		return nil, fmt.Errorf("%w, got %s, expected %s", ErrStateMismatch, state, a.state)
+	}
+
+	return a.exchange(ctx, callback.Code)
+}
+
+// BeginAuthorization starts an authorization attempt with its own random state, saved in store
+// with the ttl and metadata, such as the return URL or the user ID. It returns the url to
+// redirect the user to, as GrantApplicationAccessURL. The state set with WithState is not used.
+func (a *authorizationCodeFlow) BeginAuthorization(
+	w http.ResponseWriter,
+	r *http.Request,
+	store StateStore,
+	ttl time.Duration,
+	metadata map[string]string,
+) (*url.URL, error) {
+	state, err := NewAuthState(ttl, metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := a.grantApplicationAccessURL(state.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := store.Save(w, r, state); err != nil {
+		return nil, fmt.Errorf("failed to save state: %w", err)
+	}
+
+	return u, nil
+}
+
+// CompleteAuthorization completes an authorization attempt started with BeginAuthorization. It
+// validates and consumes the state of the redirect request r from store, then exchanges the code
+// for the access token. It returns ErrInvalidState if the state is unknown, expired, or was
+// already consumed, and an *AuthorizationError along with the state when the user declined.
+func (a *authorizationCodeFlow) CompleteAuthorization(
+	w http.ResponseWriter,
+	r *http.Request,
+	store StateStore,
+) (*AccessToken, *AuthState, error) {
+	state, err := store.Consume(w, r, r.URL.Query().Get(FieldState))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The state is consumed before the callback is parsed, so a declined consent uses it up too.
+	callback, err := ParseCallback(r.URL)
+	if err != nil {
+		return nil, state, err
+	}
+
+	if err := a.ruName.checkCallbackURL(callbackURL(r)); err != nil {
+		return nil, state, err
+	}
+
+	token, err := a.exchange(r.Context(), callback.Code)
+	if err != nil {
+		return nil, state, err
+	}
+
+	return token, state, nil
+}
+
+// exchange exchanges the code in a request to the token endpoint for the access token. The
+// scopes of the flow are recorded as the scopes granted to the access and refresh tokens.
+func (a *authorizationCodeFlow) exchange(ctx context.Context, code string) (*AccessToken, error) {
+	scopes, err := ExpandScopes(a.scopes)
+	if err != nil {
+		return nil, err
 	}
 
 	rb := RequestBody{}
 	rb.Set(FieldGrantType, a.grantType)
 	rb.Set(FieldCode, code)
-	rb.Set(FieldRedirectURI, a.redirectURI)
+	rb.Set(FieldRedirectURI, a.ruName.Name)
+
+	token, err := a.accessToken(ctx, rb)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(token.Scopes) == 0 {
+		token.Scopes = scopes
+	}
 
-	requestBody := strings.NewReader(rb.Encode())
+	if len(token.RefreshTokenScopes) == 0 {
+		token.RefreshTokenScopes = append([]string(nil), token.Scopes...)
+	}
 
-	return a.accessToken(requestBody)
+	return token, nil
 }
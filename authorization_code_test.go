@@ -3,6 +3,8 @@
 package oauth2_test
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -22,8 +24,11 @@ var (
 	testClientId     = "test-client-id"
 	testClientSecret = "test-client-secret"
 	testRedirectUri  = "https://my.host.com/oauth"
-	testScopes       = []string{"a", "b", "c"}
-	testPrompt       = "test-prompt"
+	testRuName       = "Test_Company-TestApp-SBX-1a2b3c4d5-6e7f8a9b"
+	testAcceptURL    = "https://my.host.com/oauth"
+	testDeclineURL   = "https://my.host.com/declined"
+	testScopes       = []string{"https://api.ebay.com/oauth/api_scope", "https://api.ebay.com/oauth/api_scope/buy.item.feed"}
+	testPrompt       = oauth2.PromptLogin
 	testState        = "test-state"
 	testCode         = "test-code"
 )
@@ -69,9 +74,34 @@ func TestAuthorizationCode_GrantApplicationAccessURL(t *testing.T) {
 		assert.Contains(t, us, testClientId)
 		assert.Contains(t, us, url.QueryEscape(testRedirectUri))
 		assert.Contains(t, us, url.QueryEscape(strings.Join(testScopes, " ")))
-		assert.Contains(t, us, testPrompt)
+		assert.Contains(t, us, string(testPrompt))
 		assert.Contains(t, us, testState)
 	})
+
+	t.Run("WithLocaleAndQueryParams", func(t *testing.T) {
+		ac := client.AuthorizationCode(
+			testScopes,
+			oauth2.WithLocale("de-DE"),
+			oauth2.WithQueryParam("display", "page"),
+		)
+
+		assert.Equal(t, "de-DE", ac.Locale())
+
+		u, err := ac.GrantApplicationAccessURL()
+		require.Nil(t, err, fmt.Sprintf("%v", err))
+
+		assert.Equal(t, "de-DE", u.Query().Get(oauth2.FieldLocale))
+		assert.Equal(t, "page", u.Query().Get("display"))
+		assert.Equal(t, testClientId, u.Query().Get(oauth2.FieldClientID))
+	})
+
+	t.Run("RejectsReservedQueryParam", func(t *testing.T) {
+		ac := client.AuthorizationCode(testScopes, oauth2.WithQueryParam(oauth2.FieldScope, "other"))
+
+		_, err := ac.GrantApplicationAccessURL()
+
+		assert.True(t, errors.Is(err, oauth2.ErrReservedParam), fmt.Sprintf("%v", err))
+	})
 }
 
 func TestAuthorizationCode_ExchangeAuthorizationCodeForToken(t *testing.T) {
@@ -112,6 +142,25 @@ func TestAuthorizationCode_ExchangeAuthorizationCodeForToken(t *testing.T) {
 		assert.NotNil(t, token)
 	})
 
+	t.Run("StopsOnContextCancel", func(t *testing.T) {
+		client.SetHTTPClient(&double.BlockingHTTPClient{})
+
+		ac := client.AuthorizationCode(
+			testScopes,
+		)
+
+		testURL, err := url.Parse(fmt.Sprintf("https://test.com/redirect?code=%s", testCode))
+		require.Nil(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		token, err := ac.ExchangeAuthorizationForTokenWithContext(ctx, testURL)
+
+		assert.Nil(t, token, fmt.Sprintf("recieved token %v", token))
+		assert.True(t, errors.Is(err, context.Canceled), fmt.Sprintf("%v", err))
+	})
+
 	t.Run("ErrorsOnMissingCodeFromURL", func(t *testing.T) {
 		mockHttpClient := double.MockHTTPClient{}
 		client.SetHTTPClient(&mockHttpClient)
@@ -203,13 +252,13 @@ func TestAuthorizationCode_ExchangeAuthorizationCodeForToken(t *testing.T) {
 		require.Nil(t, err)
 
 		reqBody := fmt.Sprintf(
-			"%s=%s\n%s=%s\n%s=%s",
-			oauth2.FieldGrantType,
-			"authorization_code",
+			"%s=%s&%s=%s&%s=%s",
 			oauth2.FieldCode,
 			testCode,
+			oauth2.FieldGrantType,
+			"authorization_code",
 			oauth2.FieldRedirectURI,
-			testRedirectUri,
+			url.QueryEscape(testRedirectUri),
 		)
 
 		assert.Equal(t, reqBody, string(body))
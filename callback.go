@@ -0,0 +1,118 @@
+package oauth2
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// Fields eBay sets on the redirect back from the consent page.
+const (
+	// FieldError is set when the authorization failed, e.g. to "access_denied".
+	FieldError = "error"
+	// FieldErrorDescription is the human readable description of FieldError, if any.
+	FieldErrorDescription = "error_description"
+	// FieldIsAuthSuccessful is set to "false" when the user declined to grant access.
+	FieldIsAuthSuccessful = "isAuthSuccessful"
+	// FieldExpiresIn is the lifetime of the authorization code in seconds.
+	FieldExpiresIn = "expires_in"
+)
+
+// errorCodeAccessDenied is the error code of a declined consent, as defined in RFC 6749 section 4.1.2.1.
+const errorCodeAccessDenied = "access_denied"
+
+var (
+	// ErrConsentDeclined is returned when the user declined to grant the application access.
+	ErrConsentDeclined = errors.New("consent declined")
+	// ErrAuthorizationFailed is returned when eBay redirected with an error other than a
+	// declined consent.
+	ErrAuthorizationFailed = errors.New("authorization failed")
+	// ErrMissingCode is returned when the redirect has neither an authorization code nor an error.
+	ErrMissingCode = errors.New("missing code")
+)
+
+// Callback is the result of a successful redirect back from the consent page.
+type Callback struct {
+	// Code is the authorization code to exchange for the access token.
+	Code string
+	// State is the state token, if any.
+	State string
+	// ExpiresIn is the lifetime of the code in seconds, or 0 if eBay did not send it.
+	ExpiresIn int
+}
+
+// AuthorizationError is returned when the redirect back from the consent page reports that the
+// authorization failed. It matches ErrConsentDeclined with errors.Is when the user declined to
+// grant access, and ErrAuthorizationFailed otherwise.
+type AuthorizationError struct {
+	// Code is the OAuth2 error code, e.g. "access_denied". Empty if eBay only set
+	// isAuthSuccessful=false.
+	Code string
+	// Description is the human readable error_description provided by eBay, if any.
+	Description string
+	// State is the state token, if any.
+	State string
+}
+
+// Error satisfies the error interface.
+func (e *AuthorizationError) Error() string {
+	msg := ErrAuthorizationFailed.Error()
+	if e.Declined() {
+		msg = ErrConsentDeclined.Error()
+	}
+
+	if e.Code != "" {
+		msg = fmt.Sprintf("%s: %s", msg, e.Code)
+	}
+
+	if e.Description != "" {
+		msg = fmt.Sprintf("%s: %s", msg, e.Description)
+	}
+
+	return msg
+}
+
+// Declined reports whether the user declined to grant the application access.
+func (e *AuthorizationError) Declined() bool {
+	return e.Code == "" || e.Code == errorCodeAccessDenied
+}
+
+// Is reports whether target is ErrConsentDeclined for a declined consent, or
+// ErrAuthorizationFailed for any other failure.
+func (e *AuthorizationError) Is(target error) bool {
+	if e.Declined() {
+		return target == ErrConsentDeclined
+	}
+
+	return target == ErrAuthorizationFailed
+}
+
+// ParseCallback parses the url invoked in the GET request to the redirect URI. It returns an
+// *AuthorizationError when the user declined to grant access or eBay reported an error, and
+// ErrMissingCode when the url has no authorization code.
+func ParseCallback(reqURL *url.URL) (*Callback, error) {
+	query := reqURL.Query()
+
+	if query.Get(FieldError) != "" || query.Get(FieldIsAuthSuccessful) == "false" {
+		return nil, &AuthorizationError{
+			Code:        query.Get(FieldError),
+			Description: query.Get(FieldErrorDescription),
+			State:       query.Get(FieldState),
+		}
+	}
+
+	code := query.Get(FieldCode)
+	if code == "" {
+		return nil, fmt.Errorf("%w in query %s", ErrMissingCode, reqURL.String())
+	}
+
+	// expires_in is informational, so a malformed value is ignored.
+	expiresIn, _ := strconv.Atoi(query.Get(FieldExpiresIn))
+
+	return &Callback{
+		Code:      code,
+		State:     query.Get(FieldState),
+		ExpiresIn: expiresIn,
+	}, nil
+}
@@ -0,0 +1,206 @@
+// +build unit
+
+package oauth2_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	oauth2 "github.com/ralucas/go-ebay-oauth2"
+	"github.com/ralucas/go-ebay-oauth2/double"
+)
+
+func TestParseCallback(t *testing.T) {
+	t.Run("ParsesCode", func(t *testing.T) {
+		u, _ := url.Parse(fmt.Sprintf("%s?code=%s&state=%s&expires_in=299", testRedirectUri, testCode, testState))
+
+		callback, err := oauth2.ParseCallback(u)
+		require.Nil(t, err, fmt.Sprintf("%v", err))
+
+		assert.Equal(t, &oauth2.Callback{Code: testCode, State: testState, ExpiresIn: 299}, callback)
+	})
+
+	t.Run("DeclinedWithError", func(t *testing.T) {
+		u, _ := url.Parse(testRedirectUri + "?error=access_denied&error_description=User+declined&state=" + testState)
+
+		_, err := oauth2.ParseCallback(u)
+
+		assert.True(t, errors.Is(err, oauth2.ErrConsentDeclined), fmt.Sprintf("%v", err))
+		assert.False(t, errors.Is(err, oauth2.ErrAuthorizationFailed))
+
+		var ae *oauth2.AuthorizationError
+		require.True(t, errors.As(err, &ae))
+		assert.Equal(t, "access_denied", ae.Code)
+		assert.Equal(t, "User declined", ae.Description)
+		assert.Equal(t, testState, ae.State)
+		assert.Equal(t, "consent declined: access_denied: User declined", err.Error())
+	})
+
+	t.Run("DeclinedWithIsAuthSuccessful", func(t *testing.T) {
+		u, _ := url.Parse(testRedirectUri + "?isAuthSuccessful=false")
+
+		_, err := oauth2.ParseCallback(u)
+
+		assert.True(t, errors.Is(err, oauth2.ErrConsentDeclined), fmt.Sprintf("%v", err))
+	})
+
+	t.Run("FailedWithOtherError", func(t *testing.T) {
+		u, _ := url.Parse(testRedirectUri + "?error=invalid_scope&error_description=Bad+scope")
+
+		_, err := oauth2.ParseCallback(u)
+
+		assert.True(t, errors.Is(err, oauth2.ErrAuthorizationFailed), fmt.Sprintf("%v", err))
+		assert.False(t, errors.Is(err, oauth2.ErrConsentDeclined))
+		assert.Equal(t, "authorization failed: invalid_scope: Bad scope", err.Error())
+	})
+
+	t.Run("MissingCode", func(t *testing.T) {
+		u, _ := url.Parse(testRedirectUri + "?state=" + testState)
+
+		_, err := oauth2.ParseCallback(u)
+
+		assert.True(t, errors.Is(err, oauth2.ErrMissingCode), fmt.Sprintf("%v", err))
+	})
+}
+
+func TestAuthorizationCode_DeclinedConsent(t *testing.T) {
+	spyHttpClient := &double.SpyHTTPClient{}
+	spyHttpClient.Reset()
+
+	client := oauth2.NewClient(testBaseUrl, testClientId, testClientSecret, testRedirectUri)
+	client.SetHTTPClient(spyHttpClient)
+
+	ac := client.AuthorizationCode(testScopes)
+
+	t.Run("ExchangeAuthorizationForToken", func(t *testing.T) {
+		u, _ := url.Parse(testRedirectUri + "?error=access_denied")
+
+		_, err := ac.ExchangeAuthorizationForToken(u)
+
+		assert.True(t, errors.Is(err, oauth2.ErrConsentDeclined), fmt.Sprintf("%v", err))
+		assert.Equal(t, 0, spyHttpClient.CallCount("Do"))
+	})
+
+	t.Run("CompleteAuthorization", func(t *testing.T) {
+		store := oauth2.NewMemoryStateStore()
+
+		consentURL, err := ac.BeginAuthorization(nil, nil, store, time.Minute, map[string]string{"user_id": "1"})
+		require.Nil(t, err)
+
+		state := consentURL.Query().Get(oauth2.FieldState)
+		r := httptest.NewRequest(http.MethodGet, "/oauth?isAuthSuccessful=false&state="+state, nil)
+
+		_, authState, err := ac.CompleteAuthorization(nil, r, store)
+
+		assert.True(t, errors.Is(err, oauth2.ErrConsentDeclined), fmt.Sprintf("%v", err))
+		require.NotNil(t, authState)
+		assert.Equal(t, "1", authState.Metadata["user_id"])
+		assert.Equal(t, 0, spyHttpClient.CallCount("Do"))
+	})
+}
+
+func TestAuthorizationCode_ChecksCallbackURL(t *testing.T) {
+	client, err := oauth2.New(
+		testClientId,
+		testClientSecret,
+		testRuName,
+		oauth2.WithHTTPClient(&double.MockHTTPClient{}),
+		oauth2.WithRuName(oauth2.RuName{AcceptURL: testAcceptURL, DeclineURL: testDeclineURL}),
+	)
+	require.Nil(t, err, fmt.Sprintf("%v", err))
+
+	ac := client.AuthorizationCode(testScopes)
+
+	tests := []struct {
+		name   string
+		reqURL string
+		err    error
+	}{
+		{"AcceptURL", testAcceptURL + "?code=" + testCode, nil},
+		{"PathOnly", "/oauth?code=" + testCode, nil},
+		{"OtherHost", "https://evil.com/oauth?code=" + testCode, oauth2.ErrCallbackURLMismatch},
+		{"OtherPath", "/other?code=" + testCode, oauth2.ErrCallbackURLMismatch},
+		{"DeclinedAtDeclineURL", testDeclineURL + "?isAuthSuccessful=false", oauth2.ErrConsentDeclined},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.Parse(tt.reqURL)
+			require.Nil(t, err)
+
+			_, err = ac.ExchangeAuthorizationForToken(u)
+
+			if tt.err == nil {
+				assert.Nil(t, err, fmt.Sprintf("%v", err))
+			} else {
+				assert.True(t, errors.Is(err, tt.err), fmt.Sprintf("%v", err))
+			}
+		})
+	}
+}
+
+func TestCallbackHandler_ChecksRequestHost(t *testing.T) {
+	client, err := oauth2.New(
+		testClientId,
+		testClientSecret,
+		testRuName,
+		oauth2.WithHTTPClient(&double.MockHTTPClient{}),
+		oauth2.WithRuName(oauth2.RuName{AcceptURL: testAcceptURL}),
+	)
+	require.Nil(t, err, fmt.Sprintf("%v", err))
+
+	tests := []struct {
+		name   string
+		host   string
+		status int
+	}{
+		{"AcceptHost", "my.host.com", http.StatusOK},
+		{"OtherHost", "evil.example.com", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Run("WithFixedState", func(t *testing.T) {
+				h := &oauth2.CallbackHandler{Flow: client.AuthorizationCode(testScopes, oauth2.WithState(testState))}
+
+				r := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/oauth?code=%s&state=%s", testCode, testState), nil)
+				r.Host = tt.host
+
+				rec := httptest.NewRecorder()
+				h.ServeHTTP(rec, r)
+
+				assert.Equal(t, tt.status, rec.Code)
+			})
+
+			t.Run("WithStateStore", func(t *testing.T) {
+				states := oauth2.NewMemoryStateStore()
+				ac := client.AuthorizationCode(testScopes)
+
+				u, err := ac.BeginAuthorization(nil, nil, states, time.Minute, nil)
+				require.Nil(t, err, fmt.Sprintf("%v", err))
+
+				h := &oauth2.CallbackHandler{Flow: ac, StateStore: states}
+
+				r := httptest.NewRequest(
+					http.MethodGet,
+					fmt.Sprintf("/oauth?code=%s&state=%s", testCode, u.Query().Get(oauth2.FieldState)),
+					nil,
+				)
+				r.Host = tt.host
+
+				rec := httptest.NewRecorder()
+				h.ServeHTTP(rec, r)
+
+				assert.Equal(t, tt.status, rec.Code)
+			})
+		})
+	}
+}
@@ -1,8 +1,9 @@
 package oauth2
 
 import (
+	"context"
 	"encoding/json"
-	"io"
+	"fmt"
 	"net/http"
 	"net/url"
 	"strings"
@@ -19,6 +20,7 @@ const (
 	// Grant Types
 	GrantTypeAuthorizationCode = "authorization_code"
 	GrantTypeClientCredentials = "client_credentials"
+	GrantTypeRefreshToken      = "refresh_token"
 
 	// Fields
 	FieldCode         = "code"
@@ -29,6 +31,8 @@ const (
 	FieldClientID     = "client_id"
 	FieldScope        = "scope"
 	FieldPrompt       = "prompt"
+	FieldLocale       = "locale"
+	FieldRefreshToken = "refresh_token"
 
 	// Base urls that can be used in configuration
 	ProductionBaseURL = "https://api.ebay.com"
@@ -40,48 +44,53 @@ type Flow int
 const (
 	AuthorizationCode Flow = iota
 	ClientCredentials
+	RefreshToken
 )
 
-// AccessToken is the response from each OAuth2 flow.
-type AccessToken struct {
-	AccessToken           string `json:"access_token"`
-	ExpiresIn             int    `json:"expires_in"`
-	RefreshToken          string `json:"refresh_token"`
-	RefreshTokenExpiresIn int    `json:"refresh_token_expires_in"`
-	TokenType             string `json:"token_type"`
-}
-
-// RequestBody maps a string key to a value string. It is used for creating a "URL Encoded"
-// request body used in oauth2 post requests.
-type RequestBody map[string]string
+// RequestBody maps a string key to a list of values. It is used for creating an
+// "application/x-www-form-urlencoded" request body used in oauth2 post requests.
+type RequestBody map[string][]string
 
-// Encode encodes the values into "URL encoded" form ("bar=baz&foo=quux").
+// Encode encodes the values into "URL encoded" form ("bar=baz&foo=quux") sorted by key.
+// Values of a multi-valued key keep the order in which they were added. The result
+// round-trips with url.ParseQuery.
 func (rb RequestBody) Encode() string {
-	sb := strings.Builder{}
-
-	for key, val := range rb {
-		sb.WriteString(key)
-		sb.WriteString("=")
-		sb.WriteString(val)
-		sb.WriteString("\n")
-	}
+	return url.Values(rb).Encode()
+}
 
-	return strings.TrimSuffix(sb.String(), "\n")
+// Get gets the first value associated with the given key. If there are no values
+// associated with the key, Get returns the empty string.
+func (rb RequestBody) Get(key string) string {
+	return url.Values(rb).Get(key)
 }
 
 // Set sets the key to value. It replaces any existing values.
 func (rb RequestBody) Set(key, val string) {
-	rb[key] = val
+	url.Values(rb).Set(key, val)
+}
+
+// Add adds the value to key. It appends to any existing values associated with key.
+func (rb RequestBody) Add(key, val string) {
+	url.Values(rb).Add(key, val)
+}
+
+// Del deletes the values associated with key.
+func (rb RequestBody) Del(key string) {
+	url.Values(rb).Del(key)
 }
 
 type Oauth2Client interface {
 	BaseURL() string
+	Endpoints() Endpoints
 	ClientID() string
 	ClientSecret() string
 	RedirectURI() string
+	RuName() RuName
+	Clock() Clock
 	SetHTTPClient(HTTPClient)
-	AuthorizationCode([]string, ...authorizationCodeOption) *authorizationCodeFlow
-	ClientCredentials([]string) *clientCredentialsFlow
+	AuthorizationCode([]string, ...AuthorizationCodeOption) AuthorizationCodeFlow
+	ClientCredentials([]string) ClientCredentialsFlow
+	RefreshToken(string, []string) RefreshTokenFlow
 }
 
 type HTTPClient interface {
@@ -90,20 +99,52 @@ type HTTPClient interface {
 
 type oauth2Client struct {
 	baseURL      string
+	endpoints    Endpoints
 	clientID     string
 	clientSecret string
-	redirectURI  string
+	ruName       RuName
 	httpClient   HTTPClient
+	retryPolicy  RetryPolicy
+	userAgent    string
+	logger       Logger
+	clock        Clock
 }
 
-// NewClient creates a new Oauth2Client.
+// NewClient creates a new Oauth2Client. The endpoints are those of the environment with
+// the base URL, see EndpointsForBaseURL. The redirect URI is the RuName of the application; New
+// rejects URLs.
 func NewClient(baseURL, clientID, clientSecret, redirectURI string) Oauth2Client {
 	return &oauth2Client{
-		baseURL, clientID, clientSecret, redirectURI, http.DefaultClient,
+		baseURL:      baseURL,
+		endpoints:    EndpointsForBaseURL(baseURL),
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		ruName:       RuName{Name: redirectURI},
+		httpClient:   http.DefaultClient,
+		logger:       nopLogger{},
+		clock:        systemClock{},
 	}
 }
 
-// SetHTTPClient sets an http client that satisfies the HTTPClient interface.
+// NewClientWithEndpoints creates a new Oauth2Client using the endpoints, e.g. ProductionEndpoints,
+// SandboxEndpoints, or endpoints with overridden URLs.
+func NewClientWithEndpoints(endpoints Endpoints, clientID, clientSecret, redirectURI string) Oauth2Client {
+	return &oauth2Client{
+		baseURL:      baseURLOf(endpoints.TokenURL),
+		endpoints:    endpoints,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		ruName:       RuName{Name: redirectURI},
+		httpClient:   http.DefaultClient,
+		logger:       nopLogger{},
+		clock:        systemClock{},
+	}
+}
+
+// SetHTTPClient sets an http client that satisfies the HTTPClient interface. It changes the
+// client of every flow already created from this Oauth2Client.
+//
+// Deprecated: use New with WithHTTPClient.
 func (o *oauth2Client) SetHTTPClient(c HTTPClient) {
 	o.httpClient = c
 }
@@ -113,6 +154,11 @@ func (o *oauth2Client) BaseURL() string {
 	return o.baseURL
 }
 
+// Endpoints returns the endpoints used by the Oauth2Client
+func (o *oauth2Client) Endpoints() Endpoints {
+	return o.endpoints
+}
+
 // ClientID returns the client ID used by the Oauth2Client
 func (o *oauth2Client) ClientID() string {
 	return o.clientID
@@ -123,25 +169,71 @@ func (o *oauth2Client) ClientSecret() string {
 	return o.clientSecret
 }
 
-// RedirectURI returns the redirect uri used by the Oauth2Client
+// RedirectURI returns the redirect uri used by the Oauth2Client, which is the name of its RuName
 func (o *oauth2Client) RedirectURI() string {
-	return o.redirectURI
+	return o.ruName.Name
 }
 
-// accessToken takes a request body in io.Reader type, sends the request to the
-// oauth2 token path returning either the access token or an error.
-func (o *oauth2Client) accessToken(requestBody io.Reader) (*AccessToken, error) {
-	requestUrl, err := url.Parse(o.baseURL)
-	if err != nil {
-		return nil, err
-	}
+// RuName returns the RuName used by the Oauth2Client, with its accept and decline URLs if configured
+func (o *oauth2Client) RuName() RuName {
+	return o.ruName
+}
 
-	requestUrl.Path = TokenPath
+// Clock returns the clock used by the Oauth2Client to tell when its tokens were issued and expire
+func (o *oauth2Client) Clock() Clock {
+	return o.clock
+}
 
-	newReq, err := http.NewRequest(
+// accessToken sends the request body to the token endpoint, returning either the access
+// token or an error. The request is bound to ctx, so it stops when ctx is cancelled or its
+// deadline expires. Transient failures are retried following the client's retry policy;
+// authorization code exchanges, which are not idempotent, only when it is safe to do so.
+func (o *oauth2Client) accessToken(ctx context.Context, rb RequestBody) (*AccessToken, error) {
+	idempotent := rb.Get(FieldGrantType) != GrantTypeAuthorizationCode
+	requestBody := rb.Encode()
+
+	for attempt := 1; ; attempt++ {
+		res, err := o.doTokenRequest(ctx, requestBody)
+
+		delay, retry := o.retryPolicy.retryDelay(attempt, idempotent, res, err, o.clock.Now())
+		if !retry {
+			if err != nil {
+				return nil, err
+			}
+
+			token, err := o.decodeTokenResponse(res)
+			if err != nil {
+				return nil, err
+			}
+
+			// eBay does not return the granted scopes, which are then those requested.
+			if len(token.Scopes) == 0 && rb.Get(FieldScope) != "" {
+				token.Scopes = strings.Fields(rb.Get(FieldScope))
+			}
+
+			return token, nil
+		}
+
+		if res != nil {
+			o.logger.Printf("retrying token request in %s after status %d", delay, res.StatusCode)
+			drainAndClose(res.Body)
+		} else {
+			o.logger.Printf("retrying token request in %s after error: %v", delay, err)
+		}
+
+		if err := sleepContext(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// doTokenRequest posts the encoded request body to the token endpoint.
+func (o *oauth2Client) doTokenRequest(ctx context.Context, requestBody string) (*http.Response, error) {
+	newReq, err := http.NewRequestWithContext(
+		ctx,
 		http.MethodPost,
-		requestUrl.String(),
-		requestBody,
+		o.endpoints.TokenURL,
+		strings.NewReader(requestBody),
 	)
 	if err != nil {
 		return nil, err
@@ -151,20 +243,46 @@ func (o *oauth2Client) accessToken(requestBody io.Reader) (*AccessToken, error)
 
 	newReq.Header.Add("Content-Type", ContentType)
 
-	res, err := o.httpClient.Do(newReq)
-	if err != nil {
-		return nil, err
+	if o.userAgent != "" {
+		newReq.Header.Set("User-Agent", o.userAgent)
 	}
 
-	resBody, err := io.ReadAll(res.Body)
+	return o.httpClient.Do(newReq)
+}
+
+// decodeTokenResponse reads the token response, returning a TokenError for non-2xx statuses. The
+// body is read up to a size limit, and must be JSON. A response without a Content-Type header
+// is decoded anyway, as some proxies strip it; a body that is not JSON then fails to decode.
+func (o *oauth2Client) decodeTokenResponse(res *http.Response) (*AccessToken, error) {
+	resBody, truncated, err := readResponseBody(res)
 	if err != nil {
 		return nil, err
 	}
 
+	isJSON, hasContentType := isJSONContentType(res.Header)
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		if hasContentType && !isJSON {
+			return nil, &TokenError{StatusCode: res.StatusCode, Body: resBody}
+		}
+
+		return nil, newTokenError(res.StatusCode, resBody)
+	}
+
+	if truncated {
+		return nil, fmt.Errorf("%w: more than %d bytes, body %q", ErrResponseTooLarge, maxResponseBytes, snippet(resBody))
+	}
+
+	if hasContentType && !isJSON {
+		return nil, unexpectedResponseError(res, resBody, "not JSON")
+	}
+
 	acr := AccessToken{}
-	if json.Unmarshal(resBody, &acr) != nil {
-		return nil, err
+	if err := json.Unmarshal(resBody, &acr); err != nil {
+		return nil, unexpectedResponseError(res, resBody, fmt.Sprintf("failed to decode token response: %v", err))
 	}
 
+	acr.setExpiry(o.clock.Now())
+
 	return &acr, nil
 }
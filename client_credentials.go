@@ -1,17 +1,32 @@
 package oauth2
 
 import (
+	"context"
 	"strings"
 )
 
+// ClientCredentialsFlow is the client credentials flow, which gets an application access token.
+type ClientCredentialsFlow interface {
+	// Scopes returns the scopes requested in this flow.
+	Scopes() []string
+	// GrantType returns the grant type of this flow. Should always be "client_credentials".
+	GrantType() string
+	// AccessToken requests the access token.
+	AccessToken() (*AccessToken, error)
+	// AccessTokenWithContext is like AccessToken, bound to ctx.
+	AccessTokenWithContext(ctx context.Context) (*AccessToken, error)
+}
+
 type clientCredentialsFlow struct {
 	*oauth2Client
 	scopes    []string
 	grantType string
 }
 
-// ClientCredentials takes a scopes string array and returns the client credentials flow.
-func (o *oauth2Client) ClientCredentials(scopes []string) *clientCredentialsFlow {
+// ClientCredentials takes a scopes string array and returns the client credentials flow. Scopes
+// may be given by their short names, like "buy.item.feed", and must be granted to application
+// tokens, or AccessToken returns ErrUserScope.
+func (o *oauth2Client) ClientCredentials(scopes []string) ClientCredentialsFlow {
 	return &clientCredentialsFlow{
 		oauth2Client: o,
 		scopes:       scopes,
@@ -31,11 +46,30 @@ func (c *clientCredentialsFlow) GrantType() string {
 
 // AccessToken retrieves the access token for the client credentials oauth2 flow
 func (c *clientCredentialsFlow) AccessToken() (*AccessToken, error) {
-	rb := RequestBody{}
-	rb.Set(FieldGrantType, c.grantType)
-	rb.Set(FieldRedirectURI, c.redirectURI)
+	return c.AccessTokenWithContext(context.Background())
+}
+
+// AccessTokenWithContext retrieves the access token for the client credentials oauth2 flow,
+// stopping when ctx is cancelled or its deadline expires. Concurrent calls for the same client
+// ID and scopes share a single request to the token endpoint.
+func (c *clientCredentialsFlow) AccessTokenWithContext(ctx context.Context) (*AccessToken, error) {
+	if len(c.scopes) == 0 {
+		return nil, ErrMissingScopes
+	}
+
+	scopes, err := expandAppScopes(c.scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	key := flightKey(c.endpoints.TokenURL, c.clientID, c.clientSecret, c.grantType, scopes)
 
-	requestBody := strings.NewReader(rb.Encode())
+	return tokenFlights.do(ctx, key, func(ctx context.Context) (*AccessToken, error) {
+		rb := RequestBody{}
+		rb.Set(FieldGrantType, c.grantType)
+		rb.Set(FieldRedirectURI, c.ruName.Name)
+		rb.Set(FieldScope, strings.Join(scopes, " ")) // space-separated scopes
 
-	return c.accessToken(requestBody)
+		return c.accessToken(ctx, rb)
+	})
 }
@@ -3,10 +3,15 @@
 package oauth2_test
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -31,6 +36,72 @@ func TestClientCredentials_AccessToken(t *testing.T) {
 		assert.NotNil(t, token)
 	})
 
+	t.Run("ErrorsOnMissingScopes", func(t *testing.T) {
+		spyHttpClient := &double.SpyHTTPClient{}
+		spyHttpClient.Reset()
+
+		client.SetHTTPClient(spyHttpClient)
+
+		cc := client.ClientCredentials(
+			[]string{},
+		)
+
+		token, err := cc.AccessToken()
+
+		assert.Nil(t, token, fmt.Sprintf("recieved token %v", token))
+		assert.True(t, errors.Is(err, oauth2.ErrMissingScopes), fmt.Sprintf("%v", err))
+		assert.Equal(t, 0, spyHttpClient.CallCount("Do"))
+	})
+
+	t.Run("SendsScopeURLs", func(t *testing.T) {
+		spyHttpClient := &double.SpyHTTPClient{}
+		spyHttpClient.Reset()
+
+		client.SetHTTPClient(spyHttpClient)
+
+		cc := client.ClientCredentials(
+			[]string{
+				"https://api.ebay.com/oauth/api_scope",
+				"https://api.ebay.com/oauth/api_scope/buy.item.feed",
+			},
+		)
+
+		_, err := cc.AccessToken()
+		require.Nil(t, err)
+
+		require.Equal(t, 1, spyHttpClient.CallCount("Do"))
+
+		callReq := spyHttpClient.Calls("Do")[0].Arguments()[0].(*http.Request)
+
+		body, err := io.ReadAll(callReq.Body)
+		require.Nil(t, err)
+
+		values, err := url.ParseQuery(string(body))
+		require.Nil(t, err)
+
+		assert.Equal(
+			t,
+			"https://api.ebay.com/oauth/api_scope https://api.ebay.com/oauth/api_scope/buy.item.feed",
+			values.Get(oauth2.FieldScope),
+		)
+	})
+
+	t.Run("StopsOnContextDeadline", func(t *testing.T) {
+		client.SetHTTPClient(&double.BlockingHTTPClient{})
+
+		cc := client.ClientCredentials(
+			testScopes,
+		)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		token, err := cc.AccessTokenWithContext(ctx)
+
+		assert.Nil(t, token, fmt.Sprintf("recieved token %v", token))
+		assert.True(t, errors.Is(err, context.DeadlineExceeded), fmt.Sprintf("%v", err))
+	})
+
 	t.Run("MakesExpectedRequest", func(t *testing.T) {
 		spyHttpClient := &double.SpyHTTPClient{}
 		spyHttpClient.Reset()
@@ -67,11 +138,13 @@ func TestClientCredentials_AccessToken(t *testing.T) {
 		require.Nil(t, err)
 
 		reqBody := fmt.Sprintf(
-			"%s=%s\n%s=%s",
+			"%s=%s&%s=%s&%s=%s",
 			oauth2.FieldGrantType,
 			"client_credentials",
 			oauth2.FieldRedirectURI,
-			testRedirectUri,
+			url.QueryEscape(testRedirectUri),
+			oauth2.FieldScope,
+			url.QueryEscape(strings.Join(testScopes, " ")),
 		)
 
 		assert.Equal(t, reqBody, string(body))
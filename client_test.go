@@ -0,0 +1,130 @@
+// +build unit
+
+package oauth2_test
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	oauth2 "github.com/ralucas/go-ebay-oauth2"
+	"github.com/ralucas/go-ebay-oauth2/double"
+)
+
+func TestRequestBody_Encode(t *testing.T) {
+	t.Run("SortsByKey", func(t *testing.T) {
+		rb := oauth2.RequestBody{}
+		rb.Set("c", "3")
+		rb.Set("a", "1")
+		rb.Set("b", "2")
+
+		assert.Equal(t, "a=1&b=2&c=3", rb.Encode())
+	})
+
+	t.Run("EscapesValues", func(t *testing.T) {
+		code := "v^1.1#i^1#p^3#r^1#I^3#f^0#t^Ul4xMF8yOkZCMjA=|ab/cd+ef"
+
+		rb := oauth2.RequestBody{}
+		rb.Set(oauth2.FieldCode, code)
+		rb.Set(oauth2.FieldScope, "a b")
+
+		assert.Equal(
+			t,
+			"code=v%5E1.1%23i%5E1%23p%5E3%23r%5E1%23I%5E3%23f%5E0%23t%5EUl4xMF8yOkZCMjA%3D%7Cab%2Fcd%2Bef&scope=a+b",
+			rb.Encode(),
+		)
+	})
+
+	t.Run("EncodesMultipleValues", func(t *testing.T) {
+		rb := oauth2.RequestBody{}
+		rb.Add("b", "2")
+		rb.Add("a", "1")
+		rb.Add("b", "1")
+
+		assert.Equal(t, "a=1&b=2&b=1", rb.Encode())
+		assert.Equal(t, "2", rb.Get("b"))
+
+		rb.Set("b", "3")
+		assert.Equal(t, "a=1&b=3", rb.Encode())
+
+		rb.Del("b")
+		assert.Equal(t, "a=1", rb.Encode())
+	})
+
+	t.Run("RoundTripsWithParseQuery", func(t *testing.T) {
+		rb := oauth2.RequestBody{}
+		rb.Set(oauth2.FieldGrantType, oauth2.GrantTypeAuthorizationCode)
+		rb.Set(oauth2.FieldCode, "v^1.1#i^1#f^0#r^1&x=y")
+		rb.Set(oauth2.FieldRedirectURI, "My_App-MyApp-PRD-abc-defgh")
+		rb.Add(oauth2.FieldScope, "https://api.ebay.com/oauth/api_scope")
+		rb.Add(oauth2.FieldScope, "https://api.ebay.com/oauth/api_scope/sell.inventory")
+
+		values, err := url.ParseQuery(rb.Encode())
+		require.Nil(t, err)
+
+		assert.Equal(t, oauth2.RequestBody(values), rb)
+	})
+}
+
+func TestEndpoints(t *testing.T) {
+	t.Run("UsesAuthHostForProductionConsent", func(t *testing.T) {
+		client := oauth2.NewClient(oauth2.ProductionBaseURL, testClientId, testClientSecret, testRedirectUri)
+
+		assert.Equal(t, oauth2.ProductionEndpoints, client.Endpoints())
+
+		u, err := client.AuthorizationCode(testScopes).GrantApplicationAccessURL()
+		require.Nil(t, err)
+
+		assert.Equal(t, "auth.ebay.com", u.Host)
+		assert.Equal(t, oauth2.AuthorizePath, u.Path)
+	})
+
+	t.Run("UsesAuthHostForSandboxConsent", func(t *testing.T) {
+		client := oauth2.NewClient(oauth2.SandboxBaseURL, testClientId, testClientSecret, testRedirectUri)
+
+		assert.Equal(t, oauth2.SandboxEndpoints, client.Endpoints())
+
+		u, err := client.AuthorizationCode(testScopes).GrantApplicationAccessURL()
+		require.Nil(t, err)
+
+		assert.Equal(t, "auth.sandbox.ebay.com", u.Host)
+	})
+
+	t.Run("ServesBothPathsFromOtherBaseURLs", func(t *testing.T) {
+		endpoints := oauth2.EndpointsForBaseURL(testBaseUrl)
+
+		assert.Equal(t, testBaseUrl+oauth2.AuthorizePath, endpoints.AuthorizeURL)
+		assert.Equal(t, testBaseUrl+oauth2.TokenPath, endpoints.TokenURL)
+	})
+
+	t.Run("UsesOverriddenEndpoints", func(t *testing.T) {
+		endpoints := oauth2.Endpoints{
+			AuthorizeURL: "https://consent.test.com/authorize",
+			TokenURL:     "https://tokens.test.com/token",
+		}
+
+		client := oauth2.NewClientWithEndpoints(endpoints, testClientId, testClientSecret, testRedirectUri)
+
+		spyHttpClient := &double.SpyHTTPClient{}
+		spyHttpClient.Reset()
+
+		client.SetHTTPClient(spyHttpClient)
+
+		assert.Equal(t, "https://tokens.test.com", client.BaseURL())
+
+		u, err := client.AuthorizationCode(testScopes).GrantApplicationAccessURL()
+		require.Nil(t, err)
+
+		assert.Equal(t, "consent.test.com", u.Host)
+		assert.Equal(t, "/authorize", u.Path)
+
+		_, err = client.ClientCredentials(testScopes).AccessToken()
+		require.Nil(t, err)
+
+		callReq := spyHttpClient.Calls("Do")[0].Arguments()[0].(*http.Request)
+		assert.Equal(t, endpoints.TokenURL, callReq.URL.String())
+	})
+}
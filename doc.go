@@ -10,23 +10,23 @@
 // 		baseUrl, // oauth2.ProductionBaseUrl or oauth2.SandboxBaseUrl constants are available
 // 		clientId, // your eBay application clientId
 // 		clientSecret, // your eBay application clientSecret
-// 		redirectUri, // your application redirectUri
+// 		ruName, // your application RuName, not a URL
 // 	)
 
 // Then, create the flow you wish to pursue:
 
 // Authorization Code Flow:
 
-// 	myscopes := []string{"offer"}
+// 	myscopes := []string{"sell.inventory", "sell.fulfillment"}
 // 	ac := client.AuthorizationCode(myscopes)
 
 // Authorization Code Flow with options:
 
-// 	var opts []AuthorizationCodeOption
-// 	opts = opts.append(WithPrompt("login"))
-// 	opts = opts.append(WithState("my-state-token"))
+// 	var opts []oauth2.AuthorizationCodeOption
+// 	opts = append(opts, oauth2.WithPrompt(oauth2.PromptLogin))
+// 	opts = append(opts, oauth2.WithState("my-state-token"))
 
-// 	ac := client.AuthorizationCode(config, myscopes, opts...)
+// 	ac := client.AuthorizationCode(myscopes, opts...)
 
 // Get the request url for application access:
 
@@ -39,9 +39,16 @@
 
 // Client Credentials Flow:
 
-// 	myscopes := []string{"offer"}
+// 	myscopes := []string{"api_scope", "buy.item.feed"}
 // 	cc := client.ClientCredentials(myscopes)
 
 // 	token, err := cc.AccessToken()
 // 	// do something with the token
+
+// Refresh Token Flow:
+
+// 	rt := client.RefreshToken(token.RefreshToken, myscopes)
+
+// 	token, err := rt.AccessToken()
+// 	// do something with the token
 package oauth2
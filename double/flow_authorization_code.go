@@ -0,0 +1,110 @@
+package double
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+
+	oauth2 "github.com/ralucas/go-ebay-oauth2"
+	"github.com/ralucas/go-ebay-oauth2/spy"
+)
+
+// FakeAuthorizationCodeFlow is an oauth2.AuthorizationCodeFlow that records its calls and
+// returns the configured results, without making any request.
+type FakeAuthorizationCodeFlow struct {
+	spy.Spy
+	// RequestedScopes is returned by Scopes.
+	RequestedScopes []string
+	// FixedState is returned by State.
+	FixedState string
+	// URL is returned by GrantApplicationAccessURL and BeginAuthorization.
+	URL *url.URL
+	// Token is returned by the exchanges.
+	Token *oauth2.AccessToken
+	// AuthState is returned by CompleteAuthorization.
+	AuthState *oauth2.AuthState
+	// Err is returned by every call that can fail.
+	Err error
+}
+
+func (f *FakeAuthorizationCodeFlow) Scopes() []string {
+	return f.RequestedScopes
+}
+
+func (f *FakeAuthorizationCodeFlow) GrantType() string {
+	return oauth2.GrantTypeAuthorizationCode
+}
+
+func (f *FakeAuthorizationCodeFlow) Prompt() oauth2.Prompt {
+	return ""
+}
+
+func (f *FakeAuthorizationCodeFlow) Locale() string {
+	return ""
+}
+
+func (f *FakeAuthorizationCodeFlow) State() string {
+	return f.FixedState
+}
+
+func (f *FakeAuthorizationCodeFlow) GrantApplicationAccessURL() (*url.URL, error) {
+	f.Called()
+
+	return f.url()
+}
+
+func (f *FakeAuthorizationCodeFlow) ExchangeAuthorizationForToken(reqURL *url.URL) (*oauth2.AccessToken, error) {
+	f.Called(reqURL)
+
+	return f.token()
+}
+
+func (f *FakeAuthorizationCodeFlow) ExchangeAuthorizationForTokenWithContext(
+	ctx context.Context,
+	reqURL *url.URL,
+) (*oauth2.AccessToken, error) {
+	f.Called(ctx, reqURL)
+
+	return f.token()
+}
+
+func (f *FakeAuthorizationCodeFlow) BeginAuthorization(
+	w http.ResponseWriter,
+	r *http.Request,
+	store oauth2.StateStore,
+	ttl time.Duration,
+	metadata map[string]string,
+) (*url.URL, error) {
+	f.Called(w, r, store, ttl, metadata)
+
+	return f.url()
+}
+
+func (f *FakeAuthorizationCodeFlow) CompleteAuthorization(
+	w http.ResponseWriter,
+	r *http.Request,
+	store oauth2.StateStore,
+) (*oauth2.AccessToken, *oauth2.AuthState, error) {
+	f.Called(w, r, store)
+
+	token, err := f.token()
+
+	return token, f.AuthState, err
+}
+
+func (f *FakeAuthorizationCodeFlow) url() (*url.URL, error) {
+	if f.Err != nil {
+		return nil, f.Err
+	}
+
+	return f.URL, nil
+}
+
+func (f *FakeAuthorizationCodeFlow) token() (*oauth2.AccessToken, error) {
+	if f.Err != nil {
+		return nil, f.Err
+	}
+
+	return f.Token, nil
+}
@@ -0,0 +1,87 @@
+package double
+
+import (
+	"context"
+
+	oauth2 "github.com/ralucas/go-ebay-oauth2"
+	"github.com/ralucas/go-ebay-oauth2/spy"
+)
+
+// FakeClientCredentialsFlow is an oauth2.ClientCredentialsFlow that records its calls and
+// returns the configured token or error, without making any request.
+type FakeClientCredentialsFlow struct {
+	spy.Spy
+	// RequestedScopes is returned by Scopes.
+	RequestedScopes []string
+	// Token is returned by AccessToken.
+	Token *oauth2.AccessToken
+	// Err is returned by AccessToken when set.
+	Err error
+}
+
+func (f *FakeClientCredentialsFlow) Scopes() []string {
+	return f.RequestedScopes
+}
+
+func (f *FakeClientCredentialsFlow) GrantType() string {
+	return oauth2.GrantTypeClientCredentials
+}
+
+func (f *FakeClientCredentialsFlow) AccessToken() (*oauth2.AccessToken, error) {
+	f.Called()
+
+	return fakeToken(f.Token, f.Err)
+}
+
+func (f *FakeClientCredentialsFlow) AccessTokenWithContext(ctx context.Context) (*oauth2.AccessToken, error) {
+	f.Called(ctx)
+
+	return fakeToken(f.Token, f.Err)
+}
+
+// FakeRefreshTokenFlow is an oauth2.RefreshTokenFlow that records its calls and returns the
+// configured token or error, without making any request.
+type FakeRefreshTokenFlow struct {
+	spy.Spy
+	// RequestedScopes is returned by Scopes.
+	RequestedScopes []string
+	// Token is returned by AccessToken.
+	Token *oauth2.AccessToken
+	// Err is returned by AccessToken when set.
+	Err error
+}
+
+func (f *FakeRefreshTokenFlow) Scopes() []string {
+	return f.RequestedScopes
+}
+
+func (f *FakeRefreshTokenFlow) GrantType() string {
+	return oauth2.GrantTypeRefreshToken
+}
+
+func (f *FakeRefreshTokenFlow) AccessToken() (*oauth2.AccessToken, error) {
+	f.Called()
+
+	return fakeToken(f.Token, f.Err)
+}
+
+func (f *FakeRefreshTokenFlow) AccessTokenWithContext(ctx context.Context) (*oauth2.AccessToken, error) {
+	f.Called(ctx)
+
+	return fakeToken(f.Token, f.Err)
+}
+
+// fakeToken returns a copy of token, so callers cannot change the configured token, or err.
+func fakeToken(token *oauth2.AccessToken, err error) (*oauth2.AccessToken, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	if token == nil {
+		return nil, nil
+	}
+
+	t := *token
+
+	return &t, nil
+}
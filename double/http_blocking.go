@@ -0,0 +1,14 @@
+package double
+
+import (
+	"net/http"
+)
+
+// BlockingHTTPClient blocks every request until the request's context is done.
+type BlockingHTTPClient struct{}
+
+func (b *BlockingHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	<-req.Context().Done()
+
+	return nil, req.Context().Err()
+}
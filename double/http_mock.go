@@ -39,6 +39,7 @@ func (m *MockHTTPClient) Do(req *http.Request) (*http.Response, error) {
 		return &http.Response{
 			Status:     http.StatusText(http.StatusOK),
 			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
 			Body:       io.NopCloser(bytes.NewReader(at)),
 		}, nil
 	}
@@ -47,6 +48,16 @@ func (m *MockHTTPClient) Do(req *http.Request) (*http.Response, error) {
 		return &http.Response{
 			Status:     http.StatusText(http.StatusOK),
 			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(bytes.NewReader(at)),
+		}, nil
+	}
+
+	if strings.Contains(body, oauth2.GrantTypeRefreshToken) {
+		return &http.Response{
+			Status:     http.StatusText(http.StatusOK),
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
 			Body:       io.NopCloser(bytes.NewReader(at)),
 		}, nil
 	}
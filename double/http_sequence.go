@@ -0,0 +1,50 @@
+package double
+
+import (
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/ralucas/go-ebay-oauth2/spy"
+)
+
+// StubResponse is a response, or an error, returned by SequenceHTTPClient.
+type StubResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       string
+	Err        error
+}
+
+// SequenceHTTPClient returns its Responses in order, one per request, repeating the last one
+// once they run out.
+type SequenceHTTPClient struct {
+	spy.Spy
+	Responses []StubResponse
+}
+
+func (s *SequenceHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	s.Called(req)
+
+	i := s.CallCount("Do") - 1
+	if i >= len(s.Responses) {
+		i = len(s.Responses) - 1
+	}
+
+	stub := s.Responses[i]
+	if stub.Err != nil {
+		return nil, stub.Err
+	}
+
+	header := stub.Header
+	if header == nil {
+		header = http.Header{}
+	}
+
+	return &http.Response{
+		Status:     http.StatusText(stub.StatusCode),
+		StatusCode: stub.StatusCode,
+		Header:     header,
+		Body:       io.NopCloser(strings.NewReader(stub.Body)),
+	}, nil
+}
@@ -0,0 +1,18 @@
+package double
+
+import (
+	"net/http"
+	"time"
+)
+
+// SlowHTTPClient responds like SpyHTTPClient after waiting for Delay.
+type SlowHTTPClient struct {
+	SpyHTTPClient
+	Delay time.Duration
+}
+
+func (s *SlowHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	time.Sleep(s.Delay)
+
+	return s.SpyHTTPClient.Do(req)
+}
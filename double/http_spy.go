@@ -16,6 +16,9 @@ func (s *SpyHTTPClient) Do(req *http.Request) (*http.Response, error) {
 	s.Called(req)
 
 	return &http.Response{
-		Body: io.NopCloser(strings.NewReader("test")),
+		Status:     http.StatusText(http.StatusOK),
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(`{"access_token":"test-token","expires_in":60,"token_type":"bearer"}`)),
 	}, nil
 }
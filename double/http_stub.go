@@ -0,0 +1,29 @@
+package double
+
+import (
+	"io"
+	"net/http"
+	"strings"
+)
+
+// StubHTTPClient responds to every request with the configured status code and body, and the
+// Content-Type header if set.
+type StubHTTPClient struct {
+	StatusCode  int
+	ContentType string
+	Body        string
+}
+
+func (s *StubHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	header := http.Header{}
+	if s.ContentType != "" {
+		header.Set("Content-Type", s.ContentType)
+	}
+
+	return &http.Response{
+		Status:     http.StatusText(s.StatusCode),
+		StatusCode: s.StatusCode,
+		Header:     header,
+		Body:       io.NopCloser(strings.NewReader(s.Body)),
+	}, nil
+}
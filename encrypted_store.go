@@ -0,0 +1,275 @@
+package oauth2
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrKeyNotFound is returned by a KeyProvider when it has no key with the requested ID.
+var ErrKeyNotFound = errors.New("encryption key not found")
+
+// KeyProvider supplies the AES keys used by an EncryptedStore. Keys are identified by a version
+// ID, so records sealed with a retired key can still be opened after the current key changes.
+type KeyProvider interface {
+	// CurrentKey returns the ID and the key that new records are sealed with.
+	CurrentKey() (string, []byte, error)
+	// Key returns the key with the ID, or ErrKeyNotFound.
+	Key(id string) ([]byte, error)
+}
+
+// EncryptedStore is a Store that seals tokens with AES-GCM before putting them in a RecordStore.
+//
+// Records are sealed with the current key of the KeyProvider and opened with the key they were
+// sealed with, so a record sealed with a retired key is re-encrypted under the current key the
+// next time its token is put. The store key is authenticated with each record, so a record cannot
+// be moved to another key.
+type EncryptedStore struct {
+	records RecordStore
+	keys    KeyProvider
+}
+
+// sealedRecord is the JSON form of a sealed token.
+type sealedRecord struct {
+	KeyID      string `json:"key_id"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// NewEncryptedStore returns an EncryptedStore that keeps its sealed records in records, e.g. a
+// FileStore, with keys from keys.
+func NewEncryptedStore(records RecordStore, keys KeyProvider) *EncryptedStore {
+	return &EncryptedStore{records: records, keys: keys}
+}
+
+// Get opens and returns the token stored for key, or ErrTokenNotFound.
+func (e *EncryptedStore) Get(ctx context.Context, key string) (*AccessToken, error) {
+	data, err := e.records.GetRecord(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	record := sealedRecord{}
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("failed to decode sealed token: %w", err)
+	}
+
+	secret, err := e.keys.Key(record.KeyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get key %q: %w", record.KeyID, err)
+	}
+
+	aead, err := newAEAD(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := aead.Open(nil, record.Nonce, record.Ciphertext, []byte(key))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sealed token: %w", err)
+	}
+
+	token := &AccessToken{}
+	if err := json.Unmarshal(plaintext, token); err != nil {
+		return nil, fmt.Errorf("failed to decode stored token: %w", err)
+	}
+
+	return token, nil
+}
+
+// Put seals the token with the current key and stores it for key.
+func (e *EncryptedStore) Put(ctx context.Context, key string, token *AccessToken) error {
+	plaintext, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+
+	keyID, secret, err := e.keys.CurrentKey()
+	if err != nil {
+		return fmt.Errorf("failed to get current key: %w", err)
+	}
+
+	aead, err := newAEAD(secret)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(sealedRecord{
+		KeyID:      keyID,
+		Nonce:      nonce,
+		Ciphertext: aead.Seal(nil, nonce, plaintext, []byte(key)),
+	})
+	if err != nil {
+		return err
+	}
+
+	return e.records.PutRecord(ctx, key, data)
+}
+
+// Delete removes the token stored for key.
+func (e *EncryptedStore) Delete(ctx context.Context, key string) error {
+	return e.records.DeleteRecord(ctx, key)
+}
+
+// Keys returns the keys of every stored token, sorted, if the RecordStore implements KeyLister.
+// Otherwise it returns ErrListingUnsupported.
+func (e *EncryptedStore) Keys(ctx context.Context) ([]string, error) {
+	lister, ok := e.records.(KeyLister)
+	if !ok {
+		return nil, ErrListingUnsupported
+	}
+
+	return lister.Keys(ctx)
+}
+
+func newAEAD(secret []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// KeyRing is a KeyProvider holding a fixed set of keys.
+type KeyRing struct {
+	currentID string
+	keys      map[string][]byte
+}
+
+// NewKeyRing returns a KeyRing with the keys by ID, sealing new records with the key of currentID.
+// Keys must be 16, 24 or 32 bytes long, for AES-128, AES-192 or AES-256.
+func NewKeyRing(currentID string, keys map[string][]byte) (*KeyRing, error) {
+	if _, ok := keys[currentID]; !ok {
+		return nil, fmt.Errorf("current key %q: %w", currentID, ErrKeyNotFound)
+	}
+
+	kr := &KeyRing{currentID: currentID, keys: make(map[string][]byte, len(keys))}
+
+	for id, key := range keys {
+		switch len(key) {
+		case 16, 24, 32:
+		default:
+			return nil, fmt.Errorf("key %q has invalid length %d, must be 16, 24 or 32 bytes", id, len(key))
+		}
+
+		kr.keys[id] = append([]byte(nil), key...)
+	}
+
+	return kr, nil
+}
+
+// KeyRingFromEnv returns a KeyRing from environment variables. The ID of the current key is read
+// from <prefix>CURRENT_KEY, and each key from a <prefix>KEY_<id> variable holding the base64
+// encoded key. For example, with the prefix "EBAY_TOKEN_":
+//
+//	EBAY_TOKEN_CURRENT_KEY=v2
+//	EBAY_TOKEN_KEY_v1=<base64 encoded retired key>
+//	EBAY_TOKEN_KEY_v2=<base64 encoded current key>
+func KeyRingFromEnv(prefix string) (*KeyRing, error) {
+	currentID := os.Getenv(prefix + "CURRENT_KEY")
+	if currentID == "" {
+		return nil, fmt.Errorf("missing environment variable %sCURRENT_KEY", prefix)
+	}
+
+	keyPrefix := prefix + "KEY_"
+	keys := make(map[string][]byte)
+
+	for _, env := range os.Environ() {
+		name, value := splitEnv(env)
+		if !strings.HasPrefix(name, keyPrefix) {
+			continue
+		}
+
+		key, err := decodeKey(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", name, err)
+		}
+
+		keys[strings.TrimPrefix(name, keyPrefix)] = key
+	}
+
+	return NewKeyRing(currentID, keys)
+}
+
+// KeyRingFromDir returns a KeyRing from the files in dir, such as a mounted secret volume. Each
+// file holds a base64 encoded key, and its name is the key ID. Hidden files are ignored.
+func KeyRingFromDir(dir, currentID string) (*KeyRing, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string][]byte)
+
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		// Stat follows symlinks, as used by mounted secret volumes.
+		info, err := os.Stat(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		if !info.Mode().IsRegular() {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		key, err := decodeKey(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode key file %s: %w", entry.Name(), err)
+		}
+
+		keys[entry.Name()] = key
+	}
+
+	return NewKeyRing(currentID, keys)
+}
+
+// CurrentKey returns the ID and the key that new records are sealed with.
+func (k *KeyRing) CurrentKey() (string, []byte, error) {
+	return k.currentID, k.keys[k.currentID], nil
+}
+
+// Key returns the key with the ID, or ErrKeyNotFound.
+func (k *KeyRing) Key(id string) ([]byte, error) {
+	key, ok := k.keys[id]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+
+	return key, nil
+}
+
+func decodeKey(s string) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+}
+
+func splitEnv(env string) (string, string) {
+	i := strings.Index(env, "=")
+	if i < 0 {
+		return env, ""
+	}
+
+	return env[:i], env[i+1:]
+}
@@ -0,0 +1,157 @@
+// +build unit
+
+package oauth2_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	oauth2 "github.com/ralucas/go-ebay-oauth2"
+)
+
+var (
+	testKeyV1 = bytes.Repeat([]byte{1}, 32)
+	testKeyV2 = bytes.Repeat([]byte{2}, 32)
+)
+
+func TestEncryptedStore(t *testing.T) {
+	ctx := context.Background()
+
+	keys, err := oauth2.NewKeyRing("v1", map[string][]byte{"v1": testKeyV1})
+	require.Nil(t, err)
+
+	testStore(t, oauth2.NewEncryptedStore(oauth2.NewMemoryStore(), keys))
+
+	t.Run("DoesNotStorePlaintext", func(t *testing.T) {
+		records := oauth2.NewMemoryStore()
+		store := oauth2.NewEncryptedStore(records, keys)
+
+		require.Nil(t, store.Put(ctx, "seller", &oauth2.AccessToken{RefreshToken: testRefreshToken}))
+
+		record, err := records.GetRecord(ctx, "seller")
+		require.Nil(t, err)
+
+		assert.NotContains(t, string(record), testRefreshToken)
+	})
+
+	t.Run("ReencryptsUnderCurrentKey", func(t *testing.T) {
+		records := oauth2.NewMemoryStore()
+
+		require.Nil(t, oauth2.NewEncryptedStore(records, keys).Put(
+			ctx, "seller", &oauth2.AccessToken{RefreshToken: testRefreshToken},
+		))
+
+		rotated, err := oauth2.NewKeyRing("v2", map[string][]byte{"v1": testKeyV1, "v2": testKeyV2})
+		require.Nil(t, err)
+
+		store := oauth2.NewEncryptedStore(records, rotated)
+
+		token, err := store.Get(ctx, "seller")
+		require.Nil(t, err, fmt.Sprintf("%v", err))
+		assert.Equal(t, testRefreshToken, token.RefreshToken)
+
+		require.Nil(t, store.Put(ctx, "seller", token))
+
+		record, err := records.GetRecord(ctx, "seller")
+		require.Nil(t, err)
+
+		var sealed struct {
+			KeyID string `json:"key_id"`
+		}
+		require.Nil(t, json.Unmarshal(record, &sealed))
+		assert.Equal(t, "v2", sealed.KeyID)
+
+		// Once re-encrypted, the record no longer needs the retired key.
+		current, err := oauth2.NewKeyRing("v2", map[string][]byte{"v2": testKeyV2})
+		require.Nil(t, err)
+
+		_, err = oauth2.NewEncryptedStore(records, current).Get(ctx, "seller")
+		assert.Nil(t, err, fmt.Sprintf("%v", err))
+	})
+
+	t.Run("ErrorsOnUnknownKey", func(t *testing.T) {
+		records := oauth2.NewMemoryStore()
+
+		require.Nil(t, oauth2.NewEncryptedStore(records, keys).Put(ctx, "seller", &oauth2.AccessToken{}))
+
+		other, err := oauth2.NewKeyRing("v2", map[string][]byte{"v2": testKeyV2})
+		require.Nil(t, err)
+
+		_, err = oauth2.NewEncryptedStore(records, other).Get(ctx, "seller")
+		assert.True(t, errors.Is(err, oauth2.ErrKeyNotFound), fmt.Sprintf("%v", err))
+	})
+
+	t.Run("ErrorsOnMovedRecord", func(t *testing.T) {
+		records := oauth2.NewMemoryStore()
+		store := oauth2.NewEncryptedStore(records, keys)
+
+		require.Nil(t, store.Put(ctx, "seller-a", &oauth2.AccessToken{RefreshToken: testRefreshToken}))
+
+		record, err := records.GetRecord(ctx, "seller-a")
+		require.Nil(t, err)
+		require.Nil(t, records.PutRecord(ctx, "seller-b", record))
+
+		_, err = store.Get(ctx, "seller-b")
+		assert.NotNil(t, err)
+	})
+}
+
+func TestKeyRing(t *testing.T) {
+	t.Run("ErrorsOnMissingCurrentKey", func(t *testing.T) {
+		_, err := oauth2.NewKeyRing("v2", map[string][]byte{"v1": testKeyV1})
+		assert.True(t, errors.Is(err, oauth2.ErrKeyNotFound), fmt.Sprintf("%v", err))
+	})
+
+	t.Run("ErrorsOnInvalidKeyLength", func(t *testing.T) {
+		_, err := oauth2.NewKeyRing("v1", map[string][]byte{"v1": []byte("short")})
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ReadsKeysFromEnv", func(t *testing.T) {
+		t.Setenv("TEST_TOKEN_CURRENT_KEY", "v2")
+		t.Setenv("TEST_TOKEN_KEY_v1", base64.StdEncoding.EncodeToString(testKeyV1))
+		t.Setenv("TEST_TOKEN_KEY_v2", base64.StdEncoding.EncodeToString(testKeyV2))
+
+		kr, err := oauth2.KeyRingFromEnv("TEST_TOKEN_")
+		require.Nil(t, err, fmt.Sprintf("%v", err))
+
+		id, key, err := kr.CurrentKey()
+		require.Nil(t, err)
+		assert.Equal(t, "v2", id)
+		assert.Equal(t, testKeyV2, key)
+
+		key, err = kr.Key("v1")
+		require.Nil(t, err)
+		assert.Equal(t, testKeyV1, key)
+	})
+
+	t.Run("ReadsKeysFromDir", func(t *testing.T) {
+		dir := t.TempDir()
+
+		require.Nil(t, os.WriteFile(filepath.Join(dir, "v1"), []byte(base64.StdEncoding.EncodeToString(testKeyV1)+"\n"), 0600))
+		require.Nil(t, os.WriteFile(filepath.Join(dir, "v2"), []byte(base64.StdEncoding.EncodeToString(testKeyV2)), 0600))
+		require.Nil(t, os.WriteFile(filepath.Join(dir, ".hidden"), []byte("not a key"), 0600))
+
+		kr, err := oauth2.KeyRingFromDir(dir, "v1")
+		require.Nil(t, err, fmt.Sprintf("%v", err))
+
+		id, key, err := kr.CurrentKey()
+		require.Nil(t, err)
+		assert.Equal(t, "v1", id)
+		assert.Equal(t, testKeyV1, key)
+
+		key, err = kr.Key("v2")
+		require.Nil(t, err)
+		assert.Equal(t, testKeyV2, key)
+	})
+}
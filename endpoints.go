@@ -0,0 +1,69 @@
+package oauth2
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Endpoints are the URLs of eBay's OAuth2 endpoints. The consent page, where users are sent to
+// authorize an application, is served from a different host than the token endpoint.
+type Endpoints struct {
+	// AuthorizeURL is the URL of the consent page used in the authorization code flow.
+	AuthorizeURL string
+	// TokenURL is the URL of the token endpoint used by every flow.
+	TokenURL string
+}
+
+var (
+	// ProductionEndpoints are the endpoints of eBay's production environment.
+	ProductionEndpoints = Endpoints{
+		AuthorizeURL: "https://auth.ebay.com" + AuthorizePath,
+		TokenURL:     ProductionBaseURL + TokenPath,
+	}
+
+	// SandboxEndpoints are the endpoints of eBay's sandbox environment.
+	SandboxEndpoints = Endpoints{
+		AuthorizeURL: "https://auth.sandbox.ebay.com" + AuthorizePath,
+		TokenURL:     SandboxBaseURL + TokenPath,
+	}
+)
+
+// EndpointsForBaseURL returns the endpoints of the environment with the base URL. The
+// ProductionBaseURL and SandboxBaseURL return ProductionEndpoints and SandboxEndpoints; any other
+// base URL, e.g. of a test server, serves both AuthorizePath and TokenPath.
+func EndpointsForBaseURL(baseURL string) Endpoints {
+	switch strings.TrimSuffix(baseURL, "/") {
+	case ProductionBaseURL:
+		return ProductionEndpoints
+	case SandboxBaseURL:
+		return SandboxEndpoints
+	}
+
+	return Endpoints{
+		AuthorizeURL: withPath(baseURL, AuthorizePath),
+		TokenURL:     withPath(baseURL, TokenPath),
+	}
+}
+
+// withPath returns rawURL with its path replaced by path.
+func withPath(rawURL, path string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		// Left as is, so the error is returned when the URL is used.
+		return rawURL
+	}
+
+	u.Path = path
+
+	return u.String()
+}
+
+// baseURLOf returns the scheme and host of rawURL.
+func baseURLOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+
+	return (&url.URL{Scheme: u.Scheme, Host: u.Host}).String()
+}
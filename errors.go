@@ -0,0 +1,80 @@
+package oauth2
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Error codes returned by the token endpoint, as defined in RFC 6749 section 5.2. A TokenError
+// matches the sentinel of its code with errors.Is.
+var (
+	ErrInvalidRequest       = errors.New("invalid_request")
+	ErrInvalidClient        = errors.New("invalid_client")
+	ErrInvalidGrant         = errors.New("invalid_grant")
+	ErrInvalidScope         = errors.New("invalid_scope")
+	ErrUnauthorizedClient   = errors.New("unauthorized_client")
+	ErrUnsupportedGrantType = errors.New("unsupported_grant_type")
+)
+
+// ErrMissingScopes is returned by flows that require at least one scope when none was given.
+var ErrMissingScopes = errors.New("missing scopes")
+
+var errorCodes = map[string]error{
+	ErrInvalidRequest.Error():       ErrInvalidRequest,
+	ErrInvalidClient.Error():        ErrInvalidClient,
+	ErrInvalidGrant.Error():         ErrInvalidGrant,
+	ErrInvalidScope.Error():         ErrInvalidScope,
+	ErrUnauthorizedClient.Error():   ErrUnauthorizedClient,
+	ErrUnsupportedGrantType.Error(): ErrUnsupportedGrantType,
+}
+
+// TokenError is returned when the token endpoint responds with a non-2xx status.
+type TokenError struct {
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+	// Code is the OAuth2 error code, e.g. "invalid_grant". Empty if the body was not an OAuth2 error.
+	Code string `json:"error"`
+	// Description is the human readable error_description, if any.
+	Description string `json:"error_description"`
+	// Body is the raw response body, truncated if it is very large.
+	Body []byte `json:"-"`
+}
+
+// newTokenError builds a TokenError from the status code and body of a failed token response.
+func newTokenError(statusCode int, body []byte) *TokenError {
+	te := &TokenError{StatusCode: statusCode, Body: body}
+
+	// The body is not guaranteed to be an OAuth2 error, so a decoding failure only
+	// leaves Code and Description empty.
+	_ = json.Unmarshal(body, te)
+
+	return te
+}
+
+// Error satisfies the error interface.
+func (e *TokenError) Error() string {
+	status := fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode))
+
+	if e.Code == "" {
+		if len(e.Body) == 0 {
+			return fmt.Sprintf("token request failed with status %s", status)
+		}
+
+		return fmt.Sprintf("token request failed with status %s: %q", status, snippet(e.Body))
+	}
+
+	if e.Description == "" {
+		return fmt.Sprintf("token request failed with status %s: %s", status, e.Code)
+	}
+
+	return fmt.Sprintf("token request failed with status %s: %s: %s", status, e.Code, e.Description)
+}
+
+// Is reports whether target is the sentinel error matching the OAuth2 error code.
+func (e *TokenError) Is(target error) bool {
+	sentinel, ok := errorCodes[e.Code]
+
+	return ok && sentinel == target
+}
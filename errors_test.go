@@ -0,0 +1,63 @@
+// +build unit
+
+package oauth2_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	oauth2 "github.com/ralucas/go-ebay-oauth2"
+	"github.com/ralucas/go-ebay-oauth2/double"
+)
+
+func TestTokenError(t *testing.T) {
+	client := oauth2.NewClient(testBaseUrl, testClientId, testClientSecret, testRedirectUri)
+
+	t.Run("ReturnsTypedErrorOnErrorStatus", func(t *testing.T) {
+		body := `{"error":"invalid_grant","error_description":"the provided authorization grant code is invalid or was issued to another client"}`
+		client.SetHTTPClient(&double.StubHTTPClient{StatusCode: http.StatusBadRequest, Body: body})
+
+		token, err := client.RefreshToken(testRefreshToken, testScopes).AccessToken()
+		require.NotNil(t, err)
+		assert.Nil(t, token)
+
+		var te *oauth2.TokenError
+		require.True(t, errors.As(err, &te))
+
+		assert.Equal(t, http.StatusBadRequest, te.StatusCode)
+		assert.Equal(t, "invalid_grant", te.Code)
+		assert.Contains(t, te.Description, "authorization grant code is invalid")
+		assert.Equal(t, body, string(te.Body))
+
+		assert.True(t, errors.Is(err, oauth2.ErrInvalidGrant))
+		assert.False(t, errors.Is(err, oauth2.ErrInvalidClient))
+	})
+
+	t.Run("ReturnsTypedErrorOnNonOAuth2Body", func(t *testing.T) {
+		client.SetHTTPClient(&double.StubHTTPClient{StatusCode: http.StatusBadGateway, Body: "bad gateway"})
+
+		token, err := client.ClientCredentials(testScopes).AccessToken()
+		require.NotNil(t, err)
+		assert.Nil(t, token)
+
+		var te *oauth2.TokenError
+		require.True(t, errors.As(err, &te))
+
+		assert.Equal(t, http.StatusBadGateway, te.StatusCode)
+		assert.Empty(t, te.Code)
+		assert.Equal(t, "bad gateway", string(te.Body))
+	})
+
+	t.Run("ReturnsErrorOnUndecodableBody", func(t *testing.T) {
+		client.SetHTTPClient(&double.StubHTTPClient{StatusCode: http.StatusOK, Body: "not json"})
+
+		token, err := client.ClientCredentials(testScopes).AccessToken()
+
+		assert.Nil(t, token)
+		assert.NotNil(t, err)
+	})
+}
@@ -0,0 +1,144 @@
+package oauth2
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// tokenFlights deduplicates concurrent token requests across every client in the process, so
+// clients sharing a token URL, client ID and client secret also share their in-flight requests.
+var tokenFlights = &flightGroup{}
+
+// maxFlightRestarts caps how many times a caller starts a new request after the requests it
+// waited for were ended by the context of their callers.
+const maxFlightRestarts = 3
+
+// flightCall is an in-flight or completed token request.
+type flightCall struct {
+	done  chan struct{}
+	token *AccessToken
+	err   error
+	// ctxErr is the error of the context of the caller that made the request, once it returned.
+	ctxErr error
+}
+
+// flightGroup collapses concurrent token requests with the same key into a single request.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+// do calls fetch, unless a call with the same key is already in flight, in which case it waits
+// for and shares its result. Every caller gets its own copy of the token.
+//
+// The in-flight request is made with the context of the caller that started it. If that context
+// ended, callers whose own context is still live start a new request instead of sharing the
+// error, up to maxFlightRestarts times. Other errors, including timeouts of the HTTP client, are
+// shared.
+func (g *flightGroup) do(
+	ctx context.Context,
+	key string,
+	fetch func(context.Context) (*AccessToken, error),
+) (*AccessToken, error) {
+	for restarts := 0; ; restarts++ {
+		token, err, leaderCancelled := g.wait(ctx, key, fetch)
+		if err != nil && leaderCancelled && ctx.Err() == nil && restarts < maxFlightRestarts {
+			continue
+		}
+
+		return token, err
+	}
+}
+
+// wait shares the result of the call in flight for key, or makes the call. It reports whether the
+// context of the caller that made the call had ended when it returned.
+func (g *flightGroup) wait(
+	ctx context.Context,
+	key string,
+	fetch func(context.Context) (*AccessToken, error),
+) (*AccessToken, error, bool) {
+	g.mu.Lock()
+
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall)
+	}
+
+	call, ok := g.calls[key]
+	if ok {
+		g.mu.Unlock()
+
+		select {
+		case <-call.done:
+			token, err := call.result()
+			return token, err, call.ctxErr != nil
+		case <-ctx.Done():
+			return nil, ctx.Err(), false
+		}
+	}
+
+	call = &flightCall{done: make(chan struct{})}
+	g.calls[key] = call
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+
+		close(call.done)
+	}()
+
+	call.token, call.err = fetch(ctx)
+	call.ctxErr = ctx.Err()
+
+	token, err := call.result()
+
+	return token, err, call.ctxErr != nil
+}
+
+// result returns a copy of the call's token, or its error.
+func (c *flightCall) result() (*AccessToken, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	if c.token == nil {
+		return nil, errors.New("token request did not complete")
+	}
+
+	return copyAccessToken(c.token), nil
+}
+
+// flightKey builds the key of a token request from the token URL, client credentials, grant
+// type, the set of scopes and any extra values that make the request distinct, such as a refresh
+// token. The client secret and the extra values are hashed, so the key holds no credentials.
+func flightKey(tokenURL, clientID, clientSecret, grantType string, scopes []string, extra ...string) string {
+	sorted := append([]string(nil), scopes...)
+	sort.Strings(sorted)
+
+	parts := []string{
+		tokenURL,
+		clientID,
+		hashKeyPart(clientSecret),
+		grantType,
+		strings.Join(sorted, " "),
+	}
+
+	for _, value := range extra {
+		parts = append(parts, hashKeyPart(value))
+	}
+
+	return strings.Join(parts, "\x00")
+}
+
+// hashKeyPart returns the hex encoded SHA-256 hash of a credential that is part of a flight key.
+func hashKeyPart(value string) string {
+	sum := sha256.Sum256([]byte(value))
+
+	return hex.EncodeToString(sum[:])
+}
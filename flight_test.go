@@ -0,0 +1,183 @@
+// +build unit
+
+package oauth2_test
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	oauth2 "github.com/ralucas/go-ebay-oauth2"
+	"github.com/ralucas/go-ebay-oauth2/double"
+)
+
+func TestTokenRequests_Deduplication(t *testing.T) {
+	concurrently := func(n int, fn func(i int) (*oauth2.AccessToken, error)) ([]*oauth2.AccessToken, []error) {
+		tokens := make([]*oauth2.AccessToken, n)
+		errs := make([]error, n)
+
+		var wg sync.WaitGroup
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				tokens[i], errs[i] = fn(i)
+			}(i)
+		}
+		wg.Wait()
+
+		return tokens, errs
+	}
+
+	t.Run("SharesConcurrentClientCredentialsRequests", func(t *testing.T) {
+		slowHttpClient := &double.SlowHTTPClient{Delay: 50 * time.Millisecond}
+		slowHttpClient.Reset()
+
+		client := oauth2.NewClient(testBaseUrl, "dedup-client-id", testClientSecret, testRedirectUri)
+		client.SetHTTPClient(slowHttpClient)
+
+		tokens, errs := concurrently(20, func(i int) (*oauth2.AccessToken, error) {
+			// Scope order and short names do not matter, the scope set does.
+			if i%2 == 0 {
+				return client.ClientCredentials([]string{"buy.item.feed", "api_scope"}).AccessToken()
+			}
+
+			return client.ClientCredentials([]string{oauth2.ScopeAPI, oauth2.ScopeBuyItemFeed}).AccessToken()
+		})
+
+		for i := range tokens {
+			require.Nil(t, errs[i], fmt.Sprintf("%v", errs[i]))
+			assert.Equal(t, "test-token", tokens[i].AccessToken)
+		}
+
+		assert.Equal(t, 1, slowHttpClient.CallCount("Do"))
+
+		// Each caller gets its own copy of the token.
+		tokens[0].AccessToken = "modified"
+		assert.Equal(t, "test-token", tokens[1].AccessToken)
+	})
+
+	t.Run("KeepsDistinctScopeSetsApart", func(t *testing.T) {
+		slowHttpClient := &double.SlowHTTPClient{Delay: 50 * time.Millisecond}
+		slowHttpClient.Reset()
+
+		client := oauth2.NewClient(testBaseUrl, "distinct-client-id", testClientSecret, testRedirectUri)
+		client.SetHTTPClient(slowHttpClient)
+
+		_, errs := concurrently(10, func(i int) (*oauth2.AccessToken, error) {
+			scopes := []string{oauth2.ScopeAPI}
+			if i%2 == 0 {
+				scopes = append(scopes, oauth2.ScopeBuyItemFeed)
+			}
+
+			return client.ClientCredentials(scopes).AccessToken()
+		})
+
+		for _, err := range errs {
+			require.Nil(t, err, fmt.Sprintf("%v", err))
+		}
+
+		assert.Equal(t, 2, slowHttpClient.CallCount("Do"))
+	})
+
+	t.Run("SharesConcurrentRefreshRequests", func(t *testing.T) {
+		slowHttpClient := &double.SlowHTTPClient{Delay: 50 * time.Millisecond}
+		slowHttpClient.Reset()
+
+		client := oauth2.NewClient(testBaseUrl, "refresh-client-id", testClientSecret, testRedirectUri)
+		client.SetHTTPClient(slowHttpClient)
+
+		_, errs := concurrently(10, func(i int) (*oauth2.AccessToken, error) {
+			return client.RefreshToken(testRefreshToken, testScopes).AccessToken()
+		})
+
+		for _, err := range errs {
+			require.Nil(t, err, fmt.Sprintf("%v", err))
+		}
+
+		assert.Equal(t, 1, slowHttpClient.CallCount("Do"))
+	})
+
+	t.Run("DoesNotShareCancellationOfOtherCallers", func(t *testing.T) {
+		slowHttpClient := &double.SlowHTTPClient{Delay: 50 * time.Millisecond}
+		slowHttpClient.Reset()
+
+		// Clients with the same credentials share their in-flight requests.
+		leader := oauth2.NewClient(testBaseUrl, "cancel-client-id", testClientSecret, testRedirectUri)
+		leader.SetHTTPClient(&double.BlockingHTTPClient{})
+
+		follower := oauth2.NewClient(testBaseUrl, "cancel-client-id", testClientSecret, testRedirectUri)
+		follower.SetHTTPClient(slowHttpClient)
+
+		ctx, cancel := context.WithCancel(context.Background())
+
+		leaderErr := make(chan error)
+		go func() {
+			_, err := leader.ClientCredentials(testScopes).AccessTokenWithContext(ctx)
+			leaderErr <- err
+		}()
+
+		time.Sleep(10 * time.Millisecond)
+
+		followerErr := make(chan error)
+		go func() {
+			_, err := follower.ClientCredentials(testScopes).AccessToken()
+			followerErr <- err
+		}()
+
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+
+		assert.ErrorIs(t, <-leaderErr, context.Canceled)
+		assert.Nil(t, <-followerErr)
+		assert.Equal(t, 1, slowHttpClient.CallCount("Do"))
+	})
+
+	t.Run("KeepsDistinctClientSecretsApart", func(t *testing.T) {
+		slowHttpClient := &double.SlowHTTPClient{Delay: 50 * time.Millisecond}
+		slowHttpClient.Reset()
+
+		client := oauth2.NewClient(testBaseUrl, "secret-client-id", testClientSecret, testRedirectUri)
+		client.SetHTTPClient(slowHttpClient)
+
+		rotated := oauth2.NewClient(testBaseUrl, "secret-client-id", "rotated-client-secret", testRedirectUri)
+		rotated.SetHTTPClient(slowHttpClient)
+
+		_, errs := concurrently(10, func(i int) (*oauth2.AccessToken, error) {
+			if i%2 == 0 {
+				return rotated.ClientCredentials(testScopes).AccessToken()
+			}
+
+			return client.ClientCredentials(testScopes).AccessToken()
+		})
+
+		for _, err := range errs {
+			require.Nil(t, err, fmt.Sprintf("%v", err))
+		}
+
+		assert.Equal(t, 2, slowHttpClient.CallCount("Do"))
+	})
+
+	t.Run("DoesNotRestartRequestsThatTimedOut", func(t *testing.T) {
+		// An http.Client timeout counts as context.DeadlineExceeded, while the context of the
+		// caller is still live.
+		timeoutErr := &url.Error{Op: "Post", URL: testBaseUrl, Err: context.DeadlineExceeded}
+
+		httpClient := &double.SequenceHTTPClient{Responses: []double.StubResponse{{Err: timeoutErr}}}
+		httpClient.Reset()
+
+		client := oauth2.NewClient(testBaseUrl, "timeout-client-id", testClientSecret, testRedirectUri)
+		client.SetHTTPClient(httpClient)
+
+		_, err := client.ClientCredentials(testScopes).AccessTokenWithContext(context.Background())
+
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Equal(t, 1, httpClient.CallCount("Do"))
+	})
+}
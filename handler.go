@@ -0,0 +1,165 @@
+package oauth2
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// ErrMissingStateValidation is returned by AuthorizeHandler and CallbackHandler when they have
+// no StateStore and their flow has no state, so the state of the callback cannot be validated.
+var ErrMissingStateValidation = errors.New("missing state validation: set a StateStore or WithState")
+
+// AuthorizeHandler is an http.Handler that starts the authorization code flow, redirecting the
+// user to the consent page at the GrantApplicationAccessURL of Flow.
+type AuthorizeHandler struct {
+	// Flow is the authorization code flow to start. It is required.
+	Flow AuthorizationCodeFlow
+	// StateStore, when set, gives each authorization attempt its own random state, saved in the
+	// store. The CallbackHandler must use the same store. Otherwise the state of Flow is used,
+	// and Flow must have one, set with WithState.
+	StateStore StateStore
+	// StateTTL is how long an attempt is valid. Defaults to DefaultStateTTL.
+	StateTTL time.Duration
+	// Metadata returns the metadata saved with the state of an attempt, such as the return URL
+	// or the user ID. Optional.
+	Metadata func(r *http.Request) map[string]string
+	// OnFailure is called when the consent URL cannot be built. Defaults to responding with
+	// 500 Internal Server Error.
+	OnFailure func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+// ServeHTTP redirects to the consent page.
+func (h *AuthorizeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u, err := h.consentURL(w, r)
+	if err != nil {
+		h.fail(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, u.String(), http.StatusFound)
+}
+
+func (h *AuthorizeHandler) consentURL(w http.ResponseWriter, r *http.Request) (*url.URL, error) {
+	if h.StateStore == nil {
+		if h.Flow.State() == "" {
+			return nil, ErrMissingStateValidation
+		}
+
+		return h.Flow.GrantApplicationAccessURL()
+	}
+
+	var metadata map[string]string
+	if h.Metadata != nil {
+		metadata = h.Metadata(r)
+	}
+
+	return h.Flow.BeginAuthorization(w, r, h.StateStore, h.StateTTL, metadata)
+}
+
+func (h *AuthorizeHandler) fail(w http.ResponseWriter, r *http.Request, err error) {
+	if h.OnFailure != nil {
+		h.OnFailure(w, r, err)
+		return
+	}
+
+	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
+
+// CallbackHandler is an http.Handler for the redirect route of the authorization code flow. It
+// validates the state, exchanges the authorization code for a token with Flow, puts the token in
+// Store, and then calls OnSuccess. Declined consents and every other failure call OnFailure; a
+// declined consent fails with an *AuthorizationError matching ErrConsentDeclined.
+type CallbackHandler struct {
+	// Flow is the authorization code flow that was started. It is required.
+	Flow AuthorizationCodeFlow
+	// StateStore, when set, validates and consumes the state saved by the AuthorizeHandler. The
+	// state is then available to AccountKey, OnSuccess and OnFailure with StateFromContext.
+	// Otherwise the state is validated against the state of Flow, which must have one.
+	StateStore StateStore
+	// Store is where the token is put. Optional.
+	Store Store
+	// AccountKey returns the key the token is put in Store under, e.g. the ID of the logged in
+	// user. It is required when Store is set.
+	AccountKey func(r *http.Request, token *AccessToken) (string, error)
+	// OnSuccess is called with the token and its key once it is stored. Defaults to responding
+	// with 200 OK.
+	OnSuccess func(w http.ResponseWriter, r *http.Request, key string, token *AccessToken)
+	// OnFailure is called when the authorization failed. Defaults to responding with 403 Forbidden
+	// when the consent was declined or the state is invalid, 500 Internal Server Error when the
+	// state cannot be validated, and 400 Bad Request otherwise. When
+	// a StateStore is set, the state of a declined consent is consumed too, and is available
+	// with StateFromContext.
+	OnFailure func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+// ServeHTTP handles the redirect from the consent page.
+func (h *CallbackHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token, state, err := h.exchange(w, r)
+	if state != nil {
+		r = r.WithContext(ContextWithState(r.Context(), state))
+	}
+	if err != nil {
+		h.fail(w, r, err)
+		return
+	}
+
+	var key string
+
+	if h.Store != nil {
+		if h.AccountKey == nil {
+			h.fail(w, r, errors.New("missing AccountKey for storing the token"))
+			return
+		}
+
+		key, err = h.AccountKey(r, token)
+		if err != nil {
+			h.fail(w, r, err)
+			return
+		}
+
+		if err := h.Store.Put(r.Context(), key, token); err != nil {
+			h.fail(w, r, err)
+			return
+		}
+	}
+
+	if h.OnSuccess != nil {
+		h.OnSuccess(w, r, key, token)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *CallbackHandler) exchange(w http.ResponseWriter, r *http.Request) (*AccessToken, *AuthState, error) {
+	if h.StateStore == nil {
+		if h.Flow.State() == "" {
+			return nil, nil, ErrMissingStateValidation
+		}
+
+		token, err := h.Flow.ExchangeAuthorizationForTokenWithContext(r.Context(), callbackURL(r))
+
+		return token, nil, err
+	}
+
+	return h.Flow.CompleteAuthorization(w, r, h.StateStore)
+}
+
+func (h *CallbackHandler) fail(w http.ResponseWriter, r *http.Request, err error) {
+	if h.OnFailure != nil {
+		h.OnFailure(w, r, err)
+		return
+	}
+
+	status := http.StatusBadRequest
+	switch {
+	case errors.Is(err, ErrConsentDeclined) || errors.Is(err, ErrInvalidState):
+		status = http.StatusForbidden
+	case errors.Is(err, ErrMissingStateValidation):
+		status = http.StatusInternalServerError
+	}
+
+	http.Error(w, http.StatusText(status), status)
+}
@@ -0,0 +1,222 @@
+// +build unit
+
+package oauth2_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	oauth2 "github.com/ralucas/go-ebay-oauth2"
+	"github.com/ralucas/go-ebay-oauth2/double"
+)
+
+func TestAuthorizeHandler(t *testing.T) {
+	client := oauth2.NewClient(oauth2.ProductionBaseURL, testClientId, testClientSecret, testRedirectUri)
+
+	t.Run("RedirectsToConsentPage", func(t *testing.T) {
+		h := &oauth2.AuthorizeHandler{Flow: client.AuthorizationCode(testScopes, oauth2.WithState(testState))}
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/login", nil))
+
+		assert.Equal(t, http.StatusFound, rec.Code)
+
+		location, err := url.Parse(rec.Header().Get("Location"))
+		require.Nil(t, err)
+
+		assert.Equal(t, "auth.ebay.com", location.Host)
+		assert.Equal(t, testState, location.Query().Get(oauth2.FieldState))
+	})
+
+	t.Run("CallsOnFailure", func(t *testing.T) {
+		var failure error
+
+		h := &oauth2.AuthorizeHandler{
+			Flow: client.AuthorizationCode([]string{"sell.unknown"}, oauth2.WithState(testState)),
+			OnFailure: func(w http.ResponseWriter, r *http.Request, err error) {
+				failure = err
+			},
+		}
+
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/login", nil))
+
+		assert.True(t, errors.Is(failure, oauth2.ErrUnknownScope), fmt.Sprintf("%v", failure))
+	})
+
+	t.Run("FailsWithoutStateValidation", func(t *testing.T) {
+		var failure error
+
+		h := &oauth2.AuthorizeHandler{
+			Flow: client.AuthorizationCode(testScopes),
+			OnFailure: func(w http.ResponseWriter, r *http.Request, err error) {
+				failure = err
+			},
+		}
+
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/login", nil))
+
+		assert.True(t, errors.Is(failure, oauth2.ErrMissingStateValidation), fmt.Sprintf("%v", failure))
+	})
+}
+
+func TestCallbackHandler(t *testing.T) {
+	client := oauth2.NewClient(testBaseUrl, testClientId, testClientSecret, testRedirectUri)
+	client.SetHTTPClient(&double.MockHTTPClient{})
+
+	accountKey := func(r *http.Request, token *oauth2.AccessToken) (string, error) {
+		return "seller", nil
+	}
+
+	t.Run("StoresTokenAndCallsOnSuccess", func(t *testing.T) {
+		store := oauth2.NewMemoryStore()
+
+		var successKey string
+
+		h := &oauth2.CallbackHandler{
+			Flow:       client.AuthorizationCode(testScopes, oauth2.WithState(testState)),
+			Store:      store,
+			AccountKey: accountKey,
+			OnSuccess: func(w http.ResponseWriter, r *http.Request, key string, token *oauth2.AccessToken) {
+				successKey = key
+				http.Redirect(w, r, "/account", http.StatusSeeOther)
+			},
+		}
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(
+			http.MethodGet,
+			fmt.Sprintf("/oauth?code=%s&state=%s", testCode, testState),
+			nil,
+		))
+
+		assert.Equal(t, http.StatusSeeOther, rec.Code)
+		assert.Equal(t, "seller", successKey)
+
+		token, err := store.Get(context.Background(), "seller")
+		require.Nil(t, err)
+
+		assert.Equal(t, "test-token", token.AccessToken)
+	})
+
+	t.Run("FailsOnStateMismatch", func(t *testing.T) {
+		var failure error
+
+		h := &oauth2.CallbackHandler{
+			Flow: client.AuthorizationCode(testScopes, oauth2.WithState(testState)),
+			OnFailure: func(w http.ResponseWriter, r *http.Request, err error) {
+				failure = err
+			},
+		}
+
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(
+			http.MethodGet,
+			fmt.Sprintf("/oauth?code=%s&state=other", testCode),
+			nil,
+		))
+
+		assert.True(t, errors.Is(failure, oauth2.ErrStateMismatch), fmt.Sprintf("%v", failure))
+	})
+
+	t.Run("FailsOnDeclinedConsent", func(t *testing.T) {
+		spyHttpClient := &double.SpyHTTPClient{}
+		spyHttpClient.Reset()
+
+		declinedClient := oauth2.NewClient(testBaseUrl, testClientId, testClientSecret, testRedirectUri)
+		declinedClient.SetHTTPClient(spyHttpClient)
+
+		h := &oauth2.CallbackHandler{Flow: declinedClient.AuthorizationCode(testScopes, oauth2.WithState(testState))}
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/declined?isAuthSuccessful=false&state="+testState, nil))
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Equal(t, 0, spyHttpClient.CallCount("Do"))
+	})
+
+	t.Run("FailsWithoutAccountKey", func(t *testing.T) {
+		h := &oauth2.CallbackHandler{
+			Flow:  client.AuthorizationCode(testScopes, oauth2.WithState(testState)),
+			Store: oauth2.NewMemoryStore(),
+		}
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(
+			http.MethodGet,
+			fmt.Sprintf("/oauth?code=%s&state=%s", testCode, testState),
+			nil,
+		))
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("FailsWithoutStateValidation", func(t *testing.T) {
+		spyHttpClient := &double.SpyHTTPClient{}
+		spyHttpClient.Reset()
+
+		unvalidatedClient := oauth2.NewClient(testBaseUrl, testClientId, testClientSecret, testRedirectUri)
+		unvalidatedClient.SetHTTPClient(spyHttpClient)
+
+		h := &oauth2.CallbackHandler{Flow: unvalidatedClient.AuthorizationCode(testScopes)}
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/oauth?code=%s", testCode), nil))
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.Equal(t, 0, spyHttpClient.CallCount("Do"))
+	})
+}
+
+var (
+	_ oauth2.AuthorizationCodeFlow = &double.FakeAuthorizationCodeFlow{}
+	_ oauth2.ClientCredentialsFlow = &double.FakeClientCredentialsFlow{}
+	_ oauth2.RefreshTokenFlow      = &double.FakeRefreshTokenFlow{}
+)
+
+func TestHandlers_WithFakeFlow(t *testing.T) {
+	consentURL, _ := url.Parse("https://auth.test.com/oauth2/authorize?state=fake")
+
+	flow := &double.FakeAuthorizationCodeFlow{
+		FixedState: "fake",
+		URL:        consentURL,
+		Token:      &oauth2.AccessToken{AccessToken: "fake-token", RefreshToken: "fake-refresh-token"},
+	}
+
+	t.Run("AuthorizeHandler", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		(&oauth2.AuthorizeHandler{Flow: flow}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/login", nil))
+
+		assert.Equal(t, http.StatusFound, rec.Code)
+		assert.Equal(t, consentURL.String(), rec.Header().Get("Location"))
+		assert.Equal(t, 1, flow.CallCount("GrantApplicationAccessURL"))
+	})
+
+	t.Run("CallbackHandler", func(t *testing.T) {
+		store := oauth2.NewMemoryStore()
+
+		h := &oauth2.CallbackHandler{
+			Flow:  flow,
+			Store: store,
+			AccountKey: func(r *http.Request, token *oauth2.AccessToken) (string, error) {
+				return "seller", nil
+			},
+		}
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/oauth?code=fake&state=fake", nil))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, 1, flow.CallCount("ExchangeAuthorizationForTokenWithContext"))
+
+		token, err := store.Get(context.Background(), "seller")
+		require.Nil(t, err, fmt.Sprintf("%v", err))
+		assert.Equal(t, "fake-token", token.AccessToken)
+	})
+}
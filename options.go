@@ -0,0 +1,236 @@
+package oauth2
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// DefaultTimeout is the timeout of the HTTP client created by New when none is given.
+const DefaultTimeout = 30 * time.Second
+
+// Logger logs messages of the client, such as retried token requests. It is satisfied by
+// *log.Logger.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// Clock tells the current time. It is used to compute the absolute expiry times of tokens.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// clockOf returns the clock of the client of a flow, or of a fetcher built on one, or the system
+// clock when it has none, e.g. a fake flow.
+func clockOf(v interface{}) Clock {
+	if c, ok := v.(interface{ Clock() Clock }); ok && c.Clock() != nil {
+		return c.Clock()
+	}
+
+	return systemClock{}
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Printf(format string, v ...interface{}) {}
+
+type clientConfig struct {
+	httpClient  HTTPClient
+	timeout     time.Duration
+	userAgent   string
+	endpoints   Endpoints
+	logger      Logger
+	clock       Clock
+	retryPolicy RetryPolicy
+	ruName      RuName
+}
+
+// ClientOption configures a client created by New.
+type ClientOption func(*clientConfig)
+
+// WithHTTPClient sets the HTTP client used to make token requests.
+func WithHTTPClient(c HTTPClient) ClientOption {
+	return func(cfg *clientConfig) {
+		cfg.httpClient = c
+	}
+}
+
+// WithTimeout sets the timeout of token requests. It applies to the default HTTP client, or to
+// a copy of the client given with WithHTTPClient, which must then be an *http.Client.
+func WithTimeout(timeout time.Duration) ClientOption {
+	return func(cfg *clientConfig) {
+		cfg.timeout = timeout
+	}
+}
+
+// WithUserAgent sets the User-Agent header of token requests.
+func WithUserAgent(userAgent string) ClientOption {
+	return func(cfg *clientConfig) {
+		cfg.userAgent = userAgent
+	}
+}
+
+// WithEndpoints sets the endpoints of the client. Defaults to ProductionEndpoints.
+func WithEndpoints(endpoints Endpoints) ClientOption {
+	return func(cfg *clientConfig) {
+		cfg.endpoints = endpoints
+	}
+}
+
+// WithLogger sets the logger of the client. By default, nothing is logged.
+func WithLogger(logger Logger) ClientOption {
+	return func(cfg *clientConfig) {
+		cfg.logger = logger
+	}
+}
+
+// WithClock sets the clock used to compute the absolute expiry times of tokens. Token sources and
+// Refreshers of the flows of the client use it too, to tell when tokens expire. The Expired and
+// ExpiresWithin methods of AccessToken use the system clock.
+func WithClock(clock Clock) ClientOption {
+	return func(cfg *clientConfig) {
+		cfg.clock = clock
+	}
+}
+
+// WithRetryPolicy sets the policy for retrying token requests after transient failures.
+func WithRetryPolicy(p RetryPolicy) ClientOption {
+	return func(cfg *clientConfig) {
+		cfg.retryPolicy = p
+	}
+}
+
+// WithRuName sets the RuName of the client, with its accept and decline URLs. The redirect URI
+// given to New may then be empty; otherwise it must be the name of the RuName.
+func WithRuName(ruName RuName) ClientOption {
+	return func(cfg *clientConfig) {
+		cfg.ruName = ruName
+	}
+}
+
+// New creates a new Oauth2Client with the options, validating its configuration. Unlike NewClient,
+// the default HTTP client has a timeout of DefaultTimeout, and the redirect URI must be the RuName
+// of the application: a URL fails with ErrRuNameIsURL.
+func New(clientID, clientSecret, redirectURI string, options ...ClientOption) (Oauth2Client, error) {
+	cfg := clientConfig{
+		endpoints: ProductionEndpoints,
+		logger:    nopLogger{},
+		clock:     systemClock{},
+	}
+
+	for _, opt := range options {
+		opt(&cfg)
+	}
+
+	if redirectURI != "" && cfg.ruName.Name != "" && redirectURI != cfg.ruName.Name {
+		return nil, fmt.Errorf("redirect URI %q does not match RuName %q", redirectURI, cfg.ruName.Name)
+	}
+
+	if cfg.ruName.Name == "" {
+		cfg.ruName.Name = redirectURI
+	}
+
+	if err := cfg.validate(clientID, clientSecret); err != nil {
+		return nil, err
+	}
+
+	httpClient, err := cfg.buildHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return &oauth2Client{
+		baseURL:      baseURLOf(cfg.endpoints.TokenURL),
+		endpoints:    cfg.endpoints,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		ruName:       cfg.ruName,
+		httpClient:   httpClient,
+		retryPolicy:  cfg.retryPolicy,
+		userAgent:    cfg.userAgent,
+		logger:       cfg.logger,
+		clock:        cfg.clock,
+	}, nil
+}
+
+func (cfg *clientConfig) validate(clientID, clientSecret string) error {
+	if clientID == "" {
+		return errors.New("missing client ID")
+	}
+
+	if clientSecret == "" {
+		return errors.New("missing client secret")
+	}
+
+	if err := cfg.ruName.validate(); err != nil {
+		return err
+	}
+
+	if err := validateHTTPSURL("authorize URL", cfg.endpoints.AuthorizeURL); err != nil {
+		return err
+	}
+
+	if err := validateHTTPSURL("token URL", cfg.endpoints.TokenURL); err != nil {
+		return err
+	}
+
+	if cfg.timeout < 0 {
+		return fmt.Errorf("invalid timeout %s", cfg.timeout)
+	}
+
+	if cfg.logger == nil {
+		return errors.New("missing logger")
+	}
+
+	if cfg.clock == nil {
+		return errors.New("missing clock")
+	}
+
+	return nil
+}
+
+// buildHTTPClient returns the configured HTTP client with the timeout applied.
+func (cfg *clientConfig) buildHTTPClient() (HTTPClient, error) {
+	timeout := cfg.timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+
+	if cfg.httpClient == nil {
+		return &http.Client{Timeout: timeout}, nil
+	}
+
+	if cfg.timeout == 0 {
+		return cfg.httpClient, nil
+	}
+
+	hc, ok := cfg.httpClient.(*http.Client)
+	if !ok {
+		return nil, fmt.Errorf("timeout requires an *http.Client, got %T", cfg.httpClient)
+	}
+
+	// A copy, so the timeout does not change the client given by the caller.
+	withTimeout := *hc
+	withTimeout.Timeout = timeout
+
+	return &withTimeout, nil
+}
+
+func validateHTTPSURL(name, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", name, err)
+	}
+
+	if u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("invalid %s %q: must be an absolute https URL", name, rawURL)
+	}
+
+	return nil
+}
@@ -0,0 +1,180 @@
+// +build unit
+
+package oauth2_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	oauth2 "github.com/ralucas/go-ebay-oauth2"
+	"github.com/ralucas/go-ebay-oauth2/double"
+)
+
+type fixedClock struct {
+	now time.Time
+}
+
+func (c fixedClock) Now() time.Time { return c.now }
+
+type recordingLogger struct {
+	messages []string
+}
+
+func (l *recordingLogger) Printf(format string, v ...interface{}) {
+	l.messages = append(l.messages, fmt.Sprintf(format, v...))
+}
+
+func TestNew(t *testing.T) {
+	t.Run("ValidatesConfiguration", func(t *testing.T) {
+		tests := []struct {
+			name         string
+			clientID     string
+			clientSecret string
+			redirectURI  string
+			options      []oauth2.ClientOption
+		}{
+			{"MissingClientID", "", testClientSecret, testRuName, nil},
+			{"MissingClientSecret", testClientId, "", testRuName, nil},
+			{"MissingRedirectURI", testClientId, testClientSecret, "", nil},
+			{"URLRedirectURI", testClientId, testClientSecret, testAcceptURL, nil},
+			{"MismatchingRuName", testClientId, testClientSecret, testRuName, []oauth2.ClientOption{
+				oauth2.WithRuName(oauth2.RuName{Name: "Other_Company-OtherApp-SBX-1a2b3c4d5-6e7f8a9b"}),
+			}},
+			{"URLRuName", testClientId, testClientSecret, "", []oauth2.ClientOption{
+				oauth2.WithRuName(oauth2.RuName{Name: testAcceptURL}),
+			}},
+			{"InsecureAcceptURL", testClientId, testClientSecret, testRuName, []oauth2.ClientOption{
+				oauth2.WithRuName(oauth2.RuName{AcceptURL: "http://my.host.com/oauth"}),
+			}},
+			{"MalformedDeclineURL", testClientId, testClientSecret, testRuName, []oauth2.ClientOption{
+				oauth2.WithRuName(oauth2.RuName{DeclineURL: "https://my host/%zz"}),
+			}},
+			{"InsecureTokenURL", testClientId, testClientSecret, testRuName, []oauth2.ClientOption{
+				oauth2.WithEndpoints(oauth2.Endpoints{
+					AuthorizeURL: "https://auth.test.com/oauth2/authorize",
+					TokenURL:     "http://api.test.com/identity/v1/oauth2/token",
+				}),
+			}},
+			{"RelativeAuthorizeURL", testClientId, testClientSecret, testRuName, []oauth2.ClientOption{
+				oauth2.WithEndpoints(oauth2.Endpoints{
+					AuthorizeURL: "/oauth2/authorize",
+					TokenURL:     "https://api.test.com/identity/v1/oauth2/token",
+				}),
+			}},
+			{"TimeoutWithoutHTTPClient", testClientId, testClientSecret, testRuName, []oauth2.ClientOption{
+				oauth2.WithHTTPClient(&double.SpyHTTPClient{}),
+				oauth2.WithTimeout(time.Second),
+			}},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				client, err := oauth2.New(tt.clientID, tt.clientSecret, tt.redirectURI, tt.options...)
+
+				assert.Nil(t, client)
+				assert.NotNil(t, err)
+			})
+		}
+	})
+
+	t.Run("RejectsURLAsRuName", func(t *testing.T) {
+		_, err := oauth2.New(testClientId, testClientSecret, testAcceptURL)
+
+		assert.True(t, errors.Is(err, oauth2.ErrRuNameIsURL), fmt.Sprintf("%v", err))
+	})
+
+	t.Run("SetsRuName", func(t *testing.T) {
+		ruName := oauth2.RuName{Name: testRuName, AcceptURL: testAcceptURL, DeclineURL: testDeclineURL}
+
+		client, err := oauth2.New(testClientId, testClientSecret, "", oauth2.WithRuName(ruName))
+		require.Nil(t, err, fmt.Sprintf("%v", err))
+
+		assert.Equal(t, ruName, client.RuName())
+		assert.Equal(t, testRuName, client.RedirectURI())
+	})
+
+	t.Run("DefaultsToProduction", func(t *testing.T) {
+		client, err := oauth2.New(testClientId, testClientSecret, testRuName)
+		require.Nil(t, err, fmt.Sprintf("%v", err))
+
+		assert.Equal(t, oauth2.ProductionEndpoints, client.Endpoints())
+		assert.Equal(t, oauth2.ProductionBaseURL, client.BaseURL())
+	})
+
+	t.Run("AppliesTimeoutToCopyOfHTTPClient", func(t *testing.T) {
+		httpClient := &http.Client{}
+
+		client, err := oauth2.New(
+			testClientId,
+			testClientSecret,
+			testRuName,
+			oauth2.WithHTTPClient(httpClient),
+			oauth2.WithTimeout(5*time.Second),
+		)
+		require.Nil(t, err, fmt.Sprintf("%v", err))
+		require.NotNil(t, client)
+
+		assert.Equal(t, time.Duration(0), httpClient.Timeout)
+	})
+
+	t.Run("UsesOptions", func(t *testing.T) {
+		spyHttpClient := &double.SpyHTTPClient{}
+		spyHttpClient.Reset()
+
+		now := time.Date(2021, 11, 1, 12, 0, 0, 0, time.UTC)
+
+		client, err := oauth2.New(
+			testClientId,
+			testClientSecret,
+			testRuName,
+			oauth2.WithHTTPClient(spyHttpClient),
+			oauth2.WithEndpoints(oauth2.SandboxEndpoints),
+			oauth2.WithUserAgent("my-app/1.0"),
+			oauth2.WithClock(fixedClock{now}),
+		)
+		require.Nil(t, err, fmt.Sprintf("%v", err))
+
+		token, err := client.ClientCredentials(testScopes).AccessToken()
+		require.Nil(t, err, fmt.Sprintf("%v", err))
+
+		assert.Equal(t, now, token.IssuedAt)
+		assert.Equal(t, now.Add(time.Duration(token.ExpiresIn)*time.Second), token.ExpiresAt)
+
+		callReq := spyHttpClient.Calls("Do")[0].Arguments()[0].(*http.Request)
+
+		assert.Equal(t, oauth2.SandboxEndpoints.TokenURL, callReq.URL.String())
+		assert.Equal(t, "my-app/1.0", callReq.Header.Get("User-Agent"))
+	})
+
+	t.Run("LogsRetries", func(t *testing.T) {
+		httpClient := &double.SequenceHTTPClient{Responses: []double.StubResponse{
+			{StatusCode: http.StatusServiceUnavailable},
+			{StatusCode: http.StatusOK, Body: testTokenBody},
+		}}
+		httpClient.Reset()
+
+		logger := &recordingLogger{}
+
+		client, err := oauth2.New(
+			testClientId,
+			testClientSecret,
+			testRuName,
+			oauth2.WithHTTPClient(httpClient),
+			oauth2.WithRetryPolicy(testRetryPolicy),
+			oauth2.WithLogger(logger),
+		)
+		require.Nil(t, err, fmt.Sprintf("%v", err))
+
+		_, err = client.ClientCredentials(testScopes).AccessToken()
+		require.Nil(t, err, fmt.Sprintf("%v", err))
+
+		require.Len(t, logger.messages, 1)
+		assert.Contains(t, logger.messages[0], "503")
+	})
+}
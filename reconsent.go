@@ -0,0 +1,177 @@
+package oauth2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultReconsentStateTTL is how long a re-consent URL is valid by default. It is longer than
+// DefaultStateTTL, since the URL is sent to the user, e.g. by email, rather than followed at once.
+const DefaultReconsentStateTTL = 7 * 24 * time.Hour
+
+// MetadataAccountKey is the state metadata holding the account key a re-consent URL was made for.
+const MetadataAccountKey = "account_key"
+
+// ErrInsufficientScopes is returned by CheckScopes when the stored token was not granted every
+// required scope.
+var ErrInsufficientScopes = errors.New("insufficient scopes")
+
+// ErrMissingAccountKey is returned by AccountKeyFromState when the state of the request is not
+// bound to an account.
+var ErrMissingAccountKey = errors.New("missing account key in state")
+
+// ExpiringRefreshToken is a stored token whose refresh token expires soon, found by
+// ScanExpiringRefreshTokens.
+type ExpiringRefreshToken struct {
+	// Key is the key the token is stored under, i.e. the account.
+	Key string
+	// Token is the stored token.
+	Token *AccessToken
+	// ExpiresAt is when the refresh token expires. It may be in the past.
+	ExpiresAt time.Time
+}
+
+// ScanExpiringRefreshTokens calls fn for each token in store whose refresh token expires within
+// window of now, or has already expired, e.g. to ask its user to consent again. now is usually
+// time.Now(), or the Now of the Clock of the client that issued the tokens. Tokens without a refresh
+// token or without a known refresh token expiry, such as application tokens, are skipped. The
+// store must implement KeyLister, or ErrListingUnsupported is returned. Scanning stops at the
+// first error, of the store or of fn.
+func ScanExpiringRefreshTokens(
+	ctx context.Context,
+	store Store,
+	now time.Time,
+	window time.Duration,
+	fn func(ctx context.Context, expiring ExpiringRefreshToken) error,
+) error {
+	lister, ok := store.(KeyLister)
+	if !ok {
+		return ErrListingUnsupported
+	}
+
+	keys, err := lister.Keys(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list tokens: %w", err)
+	}
+
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		token, err := store.Get(ctx, key)
+		if errors.Is(err, ErrTokenNotFound) {
+			// Deleted since the keys were listed.
+			continue
+		}
+
+		if err != nil {
+			return fmt.Errorf("failed to get token %q: %w", key, err)
+		}
+
+		if token.RefreshToken == "" || token.RefreshTokenExpiresAt.IsZero() {
+			continue
+		}
+
+		if !token.refreshTokenExpiresWithin(now, window) {
+			continue
+		}
+
+		expiring := ExpiringRefreshToken{Key: key, Token: token, ExpiresAt: token.RefreshTokenExpiresAt}
+		if err := fn(ctx, expiring); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ReconsentURL returns a GrantApplicationAccessURL of flow for the user of the account key to
+// consent again, with a state bound to the account and valid for ttl, e.g.
+// DefaultReconsentStateTTL. The state is saved in store, which must keep states on the server,
+// as MemoryStateStore does: the URL is not opened in the browser that asks for it, so cookies
+// cannot be used. The account key is in the metadata of the state under MetadataAccountKey, along
+// with the metadata given.
+func ReconsentURL(
+	flow AuthorizationCodeFlow,
+	store StateStore,
+	key string,
+	ttl time.Duration,
+	metadata map[string]string,
+) (*url.URL, error) {
+	if key == "" {
+		return nil, errors.New("missing account key")
+	}
+
+	bound := make(map[string]string, len(metadata)+1)
+	for k, v := range metadata {
+		bound[k] = v
+	}
+
+	bound[MetadataAccountKey] = key
+
+	return flow.BeginAuthorization(nil, nil, store, ttl, bound)
+}
+
+// AccountKeyFromState returns the account key a re-consent URL was made for, from the state of
+// the request. It can be used as the AccountKey of a CallbackHandler with a StateStore, so the
+// new token replaces the token of the account.
+func AccountKeyFromState(r *http.Request, token *AccessToken) (string, error) {
+	state, ok := StateFromContext(r.Context())
+	if !ok || state.Metadata[MetadataAccountKey] == "" {
+		return "", ErrMissingAccountKey
+	}
+
+	return state.Metadata[MetadataAccountKey], nil
+}
+
+// CheckScopes returns the token stored under key, and an error wrapping ErrInsufficientScopes,
+// listing the missing scopes, when the user did not consent to every one of the required scopes,
+// as reported by GrantedScopes. The token is returned along with that error, to build an
+// IncrementalAuthorizationCode flow. The stored access token may have fewer scopes, when it was
+// refreshed with a subset; HasScopes tells whether it can be used as is.
+func CheckScopes(ctx context.Context, store Store, key string, required []string) (*AccessToken, error) {
+	token, err := store.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	missing, err := missingScopes(token.GrantedScopes(), required)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(missing) > 0 {
+		return token, fmt.Errorf("%w: missing %s", ErrInsufficientScopes, strings.Join(missing, " "))
+	}
+
+	return token, nil
+}
+
+// IncrementalAuthorizationCode returns an authorization code flow of client asking for the union
+// of the GrantedScopes of token and the required scopes, so the user grants the new scopes
+// without losing the others. A nil token, or a token stored before its scopes were recorded,
+// contributes no scopes, so the required scopes should then include every scope still needed.
+func IncrementalAuthorizationCode(
+	client Oauth2Client,
+	token *AccessToken,
+	required []string,
+	options ...AuthorizationCodeOption,
+) (AuthorizationCodeFlow, error) {
+	var granted []string
+	if token != nil {
+		granted = token.GrantedScopes()
+	}
+
+	scopes, err := UnionScopes(granted, required)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.AuthorizationCode(scopes, options...), nil
+}
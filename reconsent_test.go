@@ -0,0 +1,253 @@
+// +build unit
+
+package oauth2_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	oauth2 "github.com/ralucas/go-ebay-oauth2"
+	"github.com/ralucas/go-ebay-oauth2/double"
+)
+
+// unlistableStore is a Store that does not implement KeyLister.
+type unlistableStore struct {
+	oauth2.Store
+}
+
+func TestScanExpiringRefreshTokens(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	store := oauth2.NewMemoryStore()
+
+	tokens := map[string]*oauth2.AccessToken{
+		"expired":  {AccessToken: "a", RefreshToken: "r", RefreshTokenExpiresAt: now.Add(-time.Hour)},
+		"expiring": {AccessToken: "b", RefreshToken: "r", RefreshTokenExpiresAt: now.Add(24 * time.Hour)},
+		"fresh":    {AccessToken: "c", RefreshToken: "r", RefreshTokenExpiresAt: now.Add(365 * 24 * time.Hour)},
+		"app":      {AccessToken: "d", ExpiresAt: now.Add(time.Hour)},
+		"unknown":  {AccessToken: "e", RefreshToken: "r"},
+	}
+	for key, token := range tokens {
+		require.Nil(t, store.Put(ctx, key, token))
+	}
+
+	t.Run("CallsBackForExpiringRefreshTokens", func(t *testing.T) {
+		var keys []string
+
+		err := oauth2.ScanExpiringRefreshTokens(ctx, store, time.Now(), 30*24*time.Hour, func(ctx context.Context, e oauth2.ExpiringRefreshToken) error {
+			keys = append(keys, e.Key)
+			assert.True(t, e.ExpiresAt.Equal(tokens[e.Key].RefreshTokenExpiresAt))
+
+			return nil
+		})
+		require.Nil(t, err, fmt.Sprintf("%v", err))
+
+		assert.Equal(t, []string{"expired", "expiring"}, keys)
+	})
+
+	t.Run("StopsOnCallbackError", func(t *testing.T) {
+		testErr := errors.New("test error")
+		calls := 0
+
+		err := oauth2.ScanExpiringRefreshTokens(ctx, store, time.Now(), 30*24*time.Hour, func(ctx context.Context, e oauth2.ExpiringRefreshToken) error {
+			calls++
+			return testErr
+		})
+
+		assert.True(t, errors.Is(err, testErr), fmt.Sprintf("%v", err))
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("ErrorsOnUnlistableStore", func(t *testing.T) {
+		err := oauth2.ScanExpiringRefreshTokens(ctx, unlistableStore{store}, time.Now(), time.Hour, func(ctx context.Context, e oauth2.ExpiringRefreshToken) error {
+			return nil
+		})
+
+		assert.True(t, errors.Is(err, oauth2.ErrListingUnsupported), fmt.Sprintf("%v", err))
+	})
+}
+
+func TestReconsentURL(t *testing.T) {
+	client := oauth2.NewClient(testBaseUrl, testClientId, testClientSecret, testRedirectUri)
+	client.SetHTTPClient(&double.MockHTTPClient{})
+
+	ac := client.AuthorizationCode(testScopes)
+	states := oauth2.NewMemoryStateStore()
+	tokens := oauth2.NewMemoryStore()
+
+	u, err := oauth2.ReconsentURL(ac, states, "seller/1", oauth2.DefaultReconsentStateTTL, map[string]string{"reason": "expiring"})
+	require.Nil(t, err, fmt.Sprintf("%v", err))
+
+	state := u.Query().Get(oauth2.FieldState)
+	assert.NotEmpty(t, state)
+	assert.Equal(t, testClientId, u.Query().Get(oauth2.FieldClientID))
+
+	var reason string
+
+	h := &oauth2.CallbackHandler{
+		Flow:       ac,
+		StateStore: states,
+		Store:      tokens,
+		AccountKey: oauth2.AccountKeyFromState,
+		OnSuccess: func(w http.ResponseWriter, r *http.Request, key string, token *oauth2.AccessToken) {
+			authState, _ := oauth2.StateFromContext(r.Context())
+			reason = authState.Metadata["reason"]
+		},
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/oauth?code=%s&state=%s", testCode, state), nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "expiring", reason)
+
+	token, err := tokens.Get(context.Background(), "seller/1")
+	require.Nil(t, err, fmt.Sprintf("%v", err))
+	assert.Equal(t, "test-token", token.AccessToken)
+
+	t.Run("ErrorsWithoutBoundAccount", func(t *testing.T) {
+		_, err := oauth2.AccountKeyFromState(httptest.NewRequest(http.MethodGet, "/oauth", nil), nil)
+
+		assert.True(t, errors.Is(err, oauth2.ErrMissingAccountKey), fmt.Sprintf("%v", err))
+	})
+
+	t.Run("ErrorsWithCookieStateStore", func(t *testing.T) {
+		cookies, err := oauth2.NewCookieStateStore([]byte("test-secret-test-secret-test-secret"))
+		require.Nil(t, err)
+
+		_, err = oauth2.ReconsentURL(ac, cookies, "seller/1", time.Hour, nil)
+
+		assert.NotNil(t, err)
+	})
+}
+
+func TestIncrementalConsent(t *testing.T) {
+	ctx := context.Background()
+
+	client := oauth2.NewClient(testBaseUrl, testClientId, testClientSecret, testRedirectUri)
+	client.SetHTTPClient(&double.MockHTTPClient{})
+
+	t.Run("RecordsGrantedScopes", func(t *testing.T) {
+		token, err := client.AuthorizationCode([]string{"sell.inventory"}).ExchangeAuthorizationForToken(
+			&url.URL{RawQuery: "code=" + testCode},
+		)
+		require.Nil(t, err, fmt.Sprintf("%v", err))
+		assert.Equal(t, []string{oauth2.ScopeSellInventory}, token.Scopes)
+		assert.Equal(t, []string{oauth2.ScopeSellInventory}, token.RefreshTokenScopes)
+
+		token, err = client.ClientCredentials(testScopes).AccessToken()
+		require.Nil(t, err, fmt.Sprintf("%v", err))
+		assert.Equal(t, testScopes, token.Scopes)
+	})
+
+	t.Run("KeepsScopesOnRefresh", func(t *testing.T) {
+		store := oauth2.NewMemoryStore()
+		require.Nil(t, store.Put(ctx, "seller", &oauth2.AccessToken{
+			AccessToken:  "expired-token",
+			RefreshToken: testRefreshToken,
+			Scopes:       []string{oauth2.ScopeSellInventory},
+		}))
+
+		_, err := oauth2.NewStoredTokenSource(client, store, "seller", nil).Token()
+		require.Nil(t, err, fmt.Sprintf("%v", err))
+
+		token, err := store.Get(ctx, "seller")
+		require.Nil(t, err)
+		assert.Equal(t, "test-token", token.AccessToken)
+		assert.Equal(t, []string{oauth2.ScopeSellInventory}, token.Scopes)
+	})
+
+	t.Run("KeepsGrantedScopesOnRefreshWithSubset", func(t *testing.T) {
+		granted := []string{oauth2.ScopeSellInventory, oauth2.ScopeSellFinances}
+		consented := &oauth2.AccessToken{
+			AccessToken:        "expired-token",
+			RefreshToken:       testRefreshToken,
+			Scopes:             granted,
+			RefreshTokenScopes: granted,
+		}
+
+		// Used directly, the refresh flow records the requested scopes.
+		token, err := client.RefreshToken(testRefreshToken, []string{"sell.inventory"}).AccessToken()
+		require.Nil(t, err, fmt.Sprintf("%v", err))
+		assert.Equal(t, []string{oauth2.ScopeSellInventory}, token.Scopes)
+
+		checkStored := func(t *testing.T, store oauth2.Store) {
+			token, err := oauth2.CheckScopes(ctx, store, "seller", []string{"sell.inventory", "sell.finances"})
+			require.Nil(t, err, fmt.Sprintf("%v", err))
+
+			assert.Equal(t, "test-token", token.AccessToken)
+			assert.Equal(t, []string{oauth2.ScopeSellInventory}, token.Scopes)
+			assert.Equal(t, granted, token.RefreshTokenScopes)
+			assert.True(t, token.HasScopes("sell.inventory"))
+			assert.False(t, token.HasScopes("sell.finances"))
+		}
+
+		t.Run("StoredTokenSource", func(t *testing.T) {
+			store := oauth2.NewMemoryStore()
+			require.Nil(t, store.Put(ctx, "seller", consented))
+
+			_, err := oauth2.NewStoredTokenSource(client, store, "seller", []string{"sell.inventory"}).Token()
+			require.Nil(t, err, fmt.Sprintf("%v", err))
+
+			checkStored(t, store)
+		})
+
+		t.Run("Refresher", func(t *testing.T) {
+			store := oauth2.NewMemoryStore()
+			require.Nil(t, store.Put(ctx, "seller", consented))
+
+			r, err := oauth2.NewRefresher(ctx, store)
+			require.Nil(t, err)
+			defer r.Stop()
+
+			require.Nil(t, r.RegisterAccount("seller", client, []string{"sell.inventory"}))
+
+			assert.Eventually(t, func() bool {
+				token, err := store.Get(ctx, "seller")
+				return err == nil && token.AccessToken == "test-token"
+			}, time.Second, 10*time.Millisecond)
+
+			checkStored(t, store)
+		})
+	})
+
+	t.Run("ChecksStoredScopes", func(t *testing.T) {
+		store := oauth2.NewMemoryStore()
+		require.Nil(t, store.Put(ctx, "seller", &oauth2.AccessToken{
+			AccessToken: "test-token",
+			Scopes:      []string{oauth2.ScopeSellInventory},
+		}))
+
+		token, err := oauth2.CheckScopes(ctx, store, "seller", []string{"sell.inventory"})
+		require.Nil(t, err, fmt.Sprintf("%v", err))
+		assert.NotNil(t, token)
+
+		token, err = oauth2.CheckScopes(ctx, store, "seller", []string{"sell.inventory", "sell.finances"})
+		assert.True(t, errors.Is(err, oauth2.ErrInsufficientScopes), fmt.Sprintf("%v", err))
+		assert.Contains(t, err.Error(), oauth2.ScopeSellFinances)
+		require.NotNil(t, token)
+
+		ac, err := oauth2.IncrementalAuthorizationCode(client, token, []string{"sell.finances"}, oauth2.WithPrompt(oauth2.PromptLogin))
+		require.Nil(t, err, fmt.Sprintf("%v", err))
+
+		assert.Equal(t, []string{oauth2.ScopeSellInventory, oauth2.ScopeSellFinances}, ac.Scopes())
+		assert.Equal(t, oauth2.PromptLogin, ac.Prompt())
+
+		u, err := ac.GrantApplicationAccessURL()
+		require.Nil(t, err)
+		assert.Equal(t, oauth2.ScopeSellInventory+" "+oauth2.ScopeSellFinances, u.Query().Get(oauth2.FieldScope))
+
+		_, err = oauth2.CheckScopes(ctx, store, "missing", []string{"sell.inventory"})
+		assert.True(t, errors.Is(err, oauth2.ErrTokenNotFound), fmt.Sprintf("%v", err))
+	})
+}
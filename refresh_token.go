@@ -0,0 +1,82 @@
+package oauth2
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// RefreshTokenFlow is the refresh token flow, which renews a user access token.
+type RefreshTokenFlow interface {
+	// Scopes returns the scopes requested in this flow.
+	Scopes() []string
+	// GrantType returns the grant type of this flow. Should always be "refresh_token".
+	GrantType() string
+	// AccessToken requests the new access token.
+	AccessToken() (*AccessToken, error)
+	// AccessTokenWithContext is like AccessToken, bound to ctx.
+	AccessTokenWithContext(ctx context.Context) (*AccessToken, error)
+}
+
+type refreshTokenFlow struct {
+	*oauth2Client
+	refreshToken string
+	scopes       []string
+	grantType    string
+}
+
+// RefreshToken takes a refresh token, as returned by the authorization code flow, and an optional
+// scopes string array and returns the refresh token flow. The scopes must be equal to or a subset
+// of the scopes originally granted; when empty, eBay issues the token with all of those scopes.
+// Scopes may be given by their short names, like "sell.inventory".
+func (o *oauth2Client) RefreshToken(refreshToken string, scopes []string) RefreshTokenFlow {
+	return &refreshTokenFlow{
+		oauth2Client: o,
+		refreshToken: refreshToken,
+		scopes:       scopes,
+		grantType:    GrantTypeRefreshToken,
+	}
+}
+
+// Scopes returns the scopes requested in this refresh token flow.
+func (r *refreshTokenFlow) Scopes() []string {
+	return r.scopes
+}
+
+// GrantType returns the grant type of refresh token flow. Should always be "refresh_token".
+func (r *refreshTokenFlow) GrantType() string {
+	return r.grantType
+}
+
+// AccessToken exchanges the refresh token for a new access token.
+func (r *refreshTokenFlow) AccessToken() (*AccessToken, error) {
+	return r.AccessTokenWithContext(context.Background())
+}
+
+// AccessTokenWithContext exchanges the refresh token for a new access token, stopping when ctx
+// is cancelled or its deadline expires. Concurrent calls for the same refresh token and scopes
+// share a single request to the token endpoint.
+func (r *refreshTokenFlow) AccessTokenWithContext(ctx context.Context) (*AccessToken, error) {
+	if r.refreshToken == "" {
+		return nil, fmt.Errorf("missing refresh token")
+	}
+
+	scopes, err := ExpandScopes(r.scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	key := flightKey(r.endpoints.TokenURL, r.clientID, r.clientSecret, r.grantType, scopes, r.refreshToken)
+
+	return tokenFlights.do(ctx, key, func(ctx context.Context) (*AccessToken, error) {
+		rb := RequestBody{}
+		rb.Set(FieldGrantType, r.grantType)
+		rb.Set(FieldRefreshToken, r.refreshToken)
+
+		if len(scopes) > 0 {
+			rb.Set(FieldScope, strings.Join(scopes, " "))
+		}
+
+		return r.accessToken(ctx, rb)
+	})
+}
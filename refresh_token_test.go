@@ -0,0 +1,96 @@
+// +build unit
+
+package oauth2_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	oauth2 "github.com/ralucas/go-ebay-oauth2"
+	"github.com/ralucas/go-ebay-oauth2/double"
+)
+
+var testRefreshToken = "test-refresh-token"
+
+func TestRefreshToken_AccessToken(t *testing.T) {
+	client := oauth2.NewClient(testBaseUrl, testClientId, testClientSecret, testRedirectUri)
+
+	t.Run("SuccessfullyReturnsToken", func(t *testing.T) {
+		client.SetHTTPClient(&double.MockHTTPClient{})
+
+		rt := client.RefreshToken(testRefreshToken, testScopes)
+
+		token, err := rt.AccessToken()
+		assert.Nil(t, err, fmt.Sprintf("%v", err))
+
+		assert.NotNil(t, token)
+	})
+
+	t.Run("ErrorsOnMissingRefreshToken", func(t *testing.T) {
+		spyHttpClient := &double.SpyHTTPClient{}
+		spyHttpClient.Reset()
+
+		client.SetHTTPClient(spyHttpClient)
+
+		rt := client.RefreshToken("", testScopes)
+
+		token, err := rt.AccessToken()
+
+		assert.Nil(t, token, fmt.Sprintf("recieved token %v", token))
+		assert.NotNil(t, err)
+		assert.Equal(t, 0, spyHttpClient.CallCount("Do"))
+	})
+
+	t.Run("MakesExpectedRequest", func(t *testing.T) {
+		spyHttpClient := &double.SpyHTTPClient{}
+		spyHttpClient.Reset()
+
+		client.SetHTTPClient(spyHttpClient)
+
+		rt := client.RefreshToken(testRefreshToken, testScopes)
+
+		_, err := rt.AccessToken()
+		require.Nil(t, err)
+
+		require.Equal(t, 1, spyHttpClient.CallCount("Do"))
+
+		calls := spyHttpClient.Calls("Do")
+		call := calls[0]
+		callArgs := call.Arguments()
+		callReq := callArgs[0].(*http.Request)
+
+		reqUrl := fmt.Sprintf("%s%s", testBaseUrl, oauth2.TokenPath)
+
+		assert.Equal(t, reqUrl, callReq.URL.String())
+
+		callClientID, callClientSecret, ok := callReq.BasicAuth()
+		require.True(t, ok)
+
+		assert.Equal(t, testClientId, callClientID)
+		assert.Equal(t, testClientSecret, callClientSecret)
+
+		assert.Equal(t, "application/x-www-form-urlencoded", callReq.Header.Get("Content-Type"))
+
+		body, err := io.ReadAll(callReq.Body)
+		require.Nil(t, err)
+
+		reqBody := fmt.Sprintf(
+			"%s=%s&%s=%s&%s=%s",
+			oauth2.FieldGrantType,
+			"refresh_token",
+			oauth2.FieldRefreshToken,
+			testRefreshToken,
+			oauth2.FieldScope,
+			url.QueryEscape(strings.Join(testScopes, " ")),
+		)
+
+		assert.Equal(t, reqBody, string(body))
+	})
+}
@@ -0,0 +1,313 @@
+package oauth2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultRefreshFraction is the fraction of its lifetime after which a token is refreshed by
+	// default.
+	DefaultRefreshFraction = 0.75
+	// DefaultRefreshJitter is the fraction of each refresh delay that is randomized by default.
+	DefaultRefreshJitter = 0.1
+	// DefaultRefreshRetryDelay is how long after a failed refresh it is tried again by default.
+	DefaultRefreshRetryDelay = 30 * time.Second
+)
+
+// ErrRefresherStopped is returned when a token is registered with a stopped Refresher.
+var ErrRefresherStopped = errors.New("refresher stopped")
+
+// Refresher refreshes tokens in the background, ahead of their expiry, and puts them in a Store.
+// Each registered token is refreshed once a fraction of its lifetime has passed, with jitter, so
+// tokens issued together are not all refreshed at once. It is safe for concurrent use.
+type Refresher struct {
+	store      Store
+	fraction   float64
+	jitter     float64
+	retryDelay time.Duration
+	onFailure  func(key string, err error)
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	entries map[string]*refreshEntry
+}
+
+// refreshEntry is the goroutine refreshing the token of a key.
+type refreshEntry struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// RefresherOption configures a Refresher.
+type RefresherOption func(*Refresher)
+
+// WithRefreshFraction sets the fraction, between 0 and 1, of its lifetime after which a token is
+// refreshed. Defaults to DefaultRefreshFraction.
+func WithRefreshFraction(fraction float64) RefresherOption {
+	return func(r *Refresher) {
+		r.fraction = fraction
+	}
+}
+
+// WithRefreshJitter sets the fraction, between 0 and 1, of each refresh delay that is randomized.
+// Jitter only makes refreshes earlier. Defaults to DefaultRefreshJitter.
+func WithRefreshJitter(jitter float64) RefresherOption {
+	return func(r *Refresher) {
+		r.jitter = jitter
+	}
+}
+
+// WithRefreshRetryDelay sets how long after a failed refresh it is tried again. Defaults to
+// DefaultRefreshRetryDelay.
+func WithRefreshRetryDelay(delay time.Duration) RefresherOption {
+	return func(r *Refresher) {
+		r.retryDelay = delay
+	}
+}
+
+// WithRefreshFailureHandler sets the function called with the key and the error of every failed
+// refresh, e.g. to log it or to ask the user to consent again after ErrInvalidGrant, after which
+// the key is no longer refreshed. It is called from the goroutine of the key, so it must not block
+// for long, nor call Register or Unregister.
+func WithRefreshFailureHandler(onFailure func(key string, err error)) RefresherOption {
+	return func(r *Refresher) {
+		r.onFailure = onFailure
+	}
+}
+
+// NewRefresher returns a Refresher putting the tokens it refreshes in store. It runs until ctx is
+// cancelled or Stop is called. It fails when an option is out of range.
+func NewRefresher(ctx context.Context, store Store, options ...RefresherOption) (*Refresher, error) {
+	r := &Refresher{
+		store:      store,
+		fraction:   DefaultRefreshFraction,
+		jitter:     DefaultRefreshJitter,
+		retryDelay: DefaultRefreshRetryDelay,
+		onFailure:  func(string, error) {},
+		entries:    make(map[string]*refreshEntry),
+	}
+
+	for _, opt := range options {
+		opt(r)
+	}
+
+	if err := r.validate(); err != nil {
+		return nil, err
+	}
+
+	r.ctx, r.cancel = context.WithCancel(ctx)
+
+	return r, nil
+}
+
+func (r *Refresher) validate() error {
+	if r.store == nil {
+		return errors.New("missing store")
+	}
+
+	if r.fraction <= 0 || r.fraction > 1 {
+		return fmt.Errorf("invalid refresh fraction %v, must be in (0, 1]", r.fraction)
+	}
+
+	if r.jitter < 0 || r.jitter >= 1 {
+		return fmt.Errorf("invalid refresh jitter %v, must be in [0, 1)", r.jitter)
+	}
+
+	if r.retryDelay <= 0 {
+		return fmt.Errorf("invalid refresh retry delay %s", r.retryDelay)
+	}
+
+	if r.onFailure == nil {
+		return errors.New("missing refresh failure handler")
+	}
+
+	return nil
+}
+
+// Register refreshes the token stored under key in the background with fetcher, e.g. the client
+// credentials flow of an application token. When no token is stored under key, one is fetched
+// right away. Registering a key again replaces its fetcher. A key whose refresh fails with
+// ErrInvalidGrant is no longer refreshed, until it is registered again.
+func (r *Refresher) Register(key string, fetcher AccessTokenFetcher) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.ctx.Err() != nil {
+		return ErrRefresherStopped
+	}
+
+	r.unregister(key)
+
+	ctx, cancel := context.WithCancel(r.ctx)
+	entry := &refreshEntry{cancel: cancel, done: make(chan struct{})}
+	r.entries[key] = entry
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		defer close(entry.done)
+
+		r.run(ctx, key, fetcher)
+	}()
+
+	return nil
+}
+
+// RegisterAccount refreshes the user token stored under key in the background with its refresh
+// token, as NewStoredTokenSource does. The scopes are optional, as in Oauth2Client.RefreshToken.
+func (r *Refresher) RegisterAccount(key string, client Oauth2Client, scopes []string) error {
+	return r.Register(key, &storedRefreshFetcher{client: client, store: r.store, key: key, scopes: scopes})
+}
+
+// Unregister stops refreshing the token stored under key. The token stays in the store.
+func (r *Refresher) Unregister(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.unregister(key)
+}
+
+// unregister stops the goroutine of key and waits for it. Must be called with mu held.
+func (r *Refresher) unregister(key string) {
+	entry, ok := r.entries[key]
+	if !ok {
+		return
+	}
+
+	entry.cancel()
+	<-entry.done
+
+	delete(r.entries, key)
+}
+
+// Stop stops refreshing every token, and waits for refreshes in progress to return.
+func (r *Refresher) Stop() {
+	// Cancelling with mu held keeps Register from starting a goroutine while wg is waited for.
+	r.mu.Lock()
+	r.cancel()
+	r.mu.Unlock()
+
+	r.wg.Wait()
+}
+
+// run refreshes the token of key until ctx is done, or the grant of the token is invalid. Token
+// expiries are told by the Clock of the client of fetcher when it is a flow.
+func (r *Refresher) run(ctx context.Context, key string, fetcher AccessTokenFetcher) {
+	clock := clockOf(fetcher)
+
+	delay, err := r.initialDelay(ctx, key, clock.Now())
+	if err != nil {
+		r.onFailure(key, err)
+	}
+
+	for {
+		timer := time.NewTimer(delay)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		token, err := r.refresh(ctx, key, fetcher)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err != nil {
+			r.onFailure(key, err)
+
+			if errors.Is(err, ErrInvalidGrant) {
+				// Retrying cannot help: the refresh token was revoked, or has expired.
+				return
+			}
+
+			delay = r.retryDelay
+			continue
+		}
+
+		delay = r.refreshDelay(token, clock.Now())
+	}
+}
+
+// initialDelay returns how long after now the token stored under key is due for a refresh. A
+// missing or expired token is due right away.
+func (r *Refresher) initialDelay(ctx context.Context, key string, now time.Time) (time.Duration, error) {
+	token, err := r.store.Get(ctx, key)
+	if errors.Is(err, ErrTokenNotFound) {
+		return 0, nil
+	}
+
+	if err != nil {
+		return r.retryDelay, fmt.Errorf("failed to load token: %w", err)
+	}
+
+	if token.expiresWithin(now, 0) {
+		return 0, nil
+	}
+
+	return r.refreshDelay(token, now), nil
+}
+
+// refresh fetches a new token for key and puts it in the store, completed with the stored token
+// as the caching token source does.
+func (r *Refresher) refresh(ctx context.Context, key string, fetcher AccessTokenFetcher) (*AccessToken, error) {
+	token, err := fetcher.AccessTokenWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := r.store.Get(ctx, key)
+	if err != nil && !errors.Is(err, ErrTokenNotFound) {
+		return nil, fmt.Errorf("failed to load token: %w", err)
+	}
+
+	carryOver(token, stored)
+
+	if err := r.store.Put(ctx, key, token); err != nil {
+		return nil, fmt.Errorf("failed to store token: %w", err)
+	}
+
+	return token, nil
+}
+
+// refreshDelay returns how long after now the token is due for a refresh: once the fraction of
+// its lifetime has passed, minus jitter. A token without a known lifetime is refreshed after the
+// retry delay.
+func (r *Refresher) refreshDelay(token *AccessToken, now time.Time) time.Duration {
+	issuedAt := token.IssuedAt
+	if issuedAt.IsZero() {
+		issuedAt = now
+	}
+
+	expiresAt := token.ExpiresAt
+	if expiresAt.IsZero() {
+		expiresAt = issuedAt.Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+
+	lifetime := expiresAt.Sub(issuedAt)
+	if lifetime <= 0 {
+		return r.retryDelay
+	}
+
+	offset := time.Duration(r.fraction * float64(lifetime))
+	if r.jitter > 0 {
+		offset -= time.Duration(r.jitter * rand.Float64() * float64(offset))
+	}
+
+	delay := issuedAt.Add(offset).Sub(now)
+	if delay < 0 {
+		return 0
+	}
+
+	return delay
+}
@@ -0,0 +1,277 @@
+// +build unit
+
+package oauth2_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	oauth2 "github.com/ralucas/go-ebay-oauth2"
+	"github.com/ralucas/go-ebay-oauth2/double"
+)
+
+// shortLivedFetcher issues tokens living for lifetime, or fails with err.
+type shortLivedFetcher struct {
+	lifetime time.Duration
+	err      error
+
+	mu    sync.Mutex
+	count int
+}
+
+func (f *shortLivedFetcher) AccessTokenWithContext(ctx context.Context) (*oauth2.AccessToken, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.count++
+
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	now := time.Now()
+
+	return &oauth2.AccessToken{
+		AccessToken: "test-token",
+		IssuedAt:    now,
+		ExpiresAt:   now.Add(f.lifetime),
+	}, nil
+}
+
+func (f *shortLivedFetcher) calls() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.count
+}
+
+func TestRefresher(t *testing.T) {
+	t.Run("RefreshesAheadOfExpiry", func(t *testing.T) {
+		store := oauth2.NewMemoryStore()
+		fetcher := &shortLivedFetcher{lifetime: 200 * time.Millisecond}
+
+		r, err := oauth2.NewRefresher(context.Background(), store, oauth2.WithRefreshFraction(0.5), oauth2.WithRefreshJitter(0))
+		require.Nil(t, err)
+		defer r.Stop()
+
+		require.Nil(t, r.Register("app", fetcher))
+
+		assert.Eventually(t, func() bool { return fetcher.calls() >= 3 }, 2*time.Second, 10*time.Millisecond)
+
+		token, err := store.Get(context.Background(), "app")
+		require.Nil(t, err)
+		assert.False(t, token.Expired())
+	})
+
+	t.Run("WaitsForStoredToken", func(t *testing.T) {
+		store := oauth2.NewMemoryStore()
+		now := time.Now()
+		require.Nil(t, store.Put(context.Background(), "app", &oauth2.AccessToken{
+			AccessToken: "stored-token",
+			IssuedAt:    now,
+			ExpiresAt:   now.Add(time.Hour),
+		}))
+
+		fetcher := &shortLivedFetcher{lifetime: time.Hour}
+
+		r, err := oauth2.NewRefresher(context.Background(), store)
+		require.Nil(t, err)
+		defer r.Stop()
+
+		require.Nil(t, r.Register("app", fetcher))
+
+		time.Sleep(50 * time.Millisecond)
+		assert.Equal(t, 0, fetcher.calls())
+	})
+
+	t.Run("RefreshesAccountAndKeepsRefreshToken", func(t *testing.T) {
+		client := oauth2.NewClient(testBaseUrl, testClientId, testClientSecret, testRedirectUri)
+		client.SetHTTPClient(&double.MockHTTPClient{})
+
+		store := oauth2.NewMemoryStore()
+		require.Nil(t, store.Put(context.Background(), "seller", &oauth2.AccessToken{
+			AccessToken:  "expired-token",
+			RefreshToken: testRefreshToken,
+		}))
+
+		r, err := oauth2.NewRefresher(context.Background(), store, oauth2.WithRefreshFailureHandler(func(key string, err error) {
+			t.Errorf("refresh failed: %v", err)
+		}))
+		require.Nil(t, err)
+		defer r.Stop()
+
+		require.Nil(t, r.RegisterAccount("seller", client, testScopes))
+
+		assert.Eventually(t, func() bool {
+			token, err := store.Get(context.Background(), "seller")
+			return err == nil && token.AccessToken == "test-token"
+		}, time.Second, 10*time.Millisecond)
+
+		token, err := store.Get(context.Background(), "seller")
+		require.Nil(t, err)
+		assert.Equal(t, testRefreshToken, token.RefreshToken)
+	})
+
+	t.Run("UsesClockOfClient", func(t *testing.T) {
+		spyHttpClient := &double.SpyHTTPClient{}
+		spyHttpClient.Reset()
+
+		client, err := oauth2.New(
+			testClientId,
+			testClientSecret,
+			testRuName,
+			oauth2.WithHTTPClient(spyHttpClient),
+			oauth2.WithClock(fixedClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}),
+		)
+		require.Nil(t, err)
+
+		r, err := oauth2.NewRefresher(context.Background(), oauth2.NewMemoryStore())
+		require.Nil(t, err)
+
+		require.Nil(t, r.Register("app", client.ClientCredentials(testScopes)))
+
+		time.Sleep(100 * time.Millisecond)
+		r.Stop()
+
+		// The token issued by the client expires 60 seconds after its clock, so it is not due yet.
+		assert.Equal(t, 1, spyHttpClient.CallCount("Do"))
+	})
+
+	t.Run("PublishesFailures", func(t *testing.T) {
+		testErr := errors.New("test error")
+		fetcher := &shortLivedFetcher{err: testErr}
+
+		var (
+			mu       sync.Mutex
+			failures []error
+		)
+
+		r, err := oauth2.NewRefresher(
+			context.Background(),
+			oauth2.NewMemoryStore(),
+			oauth2.WithRefreshRetryDelay(10*time.Millisecond),
+			oauth2.WithRefreshFailureHandler(func(key string, err error) {
+				mu.Lock()
+				defer mu.Unlock()
+
+				assert.Equal(t, "app", key)
+				failures = append(failures, err)
+			}),
+		)
+		require.Nil(t, err)
+		defer r.Stop()
+
+		require.Nil(t, r.Register("app", fetcher))
+
+		assert.Eventually(t, func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+
+			return len(failures) >= 2
+		}, time.Second, 10*time.Millisecond)
+
+		mu.Lock()
+		assert.True(t, errors.Is(failures[0], testErr))
+		mu.Unlock()
+	})
+
+	t.Run("StopsKeyOnInvalidGrant", func(t *testing.T) {
+		fetcher := &shortLivedFetcher{err: &oauth2.TokenError{Code: "invalid_grant"}}
+
+		failures := make(chan error, 10)
+
+		r, err := oauth2.NewRefresher(
+			context.Background(),
+			oauth2.NewMemoryStore(),
+			oauth2.WithRefreshRetryDelay(10*time.Millisecond),
+			oauth2.WithRefreshFailureHandler(func(key string, err error) {
+				failures <- err
+			}),
+		)
+		require.Nil(t, err)
+		defer r.Stop()
+
+		require.Nil(t, r.Register("app", fetcher))
+
+		assert.True(t, errors.Is(<-failures, oauth2.ErrInvalidGrant))
+
+		time.Sleep(50 * time.Millisecond)
+		assert.Equal(t, 1, fetcher.calls())
+	})
+
+	t.Run("RejectsInvalidOptions", func(t *testing.T) {
+		store := oauth2.NewMemoryStore()
+
+		for _, option := range []oauth2.RefresherOption{
+			oauth2.WithRefreshFraction(0),
+			oauth2.WithRefreshFraction(1.5),
+			oauth2.WithRefreshJitter(-0.1),
+			oauth2.WithRefreshJitter(1),
+			oauth2.WithRefreshRetryDelay(0),
+			oauth2.WithRefreshFailureHandler(nil),
+		} {
+			_, err := oauth2.NewRefresher(context.Background(), store, option)
+			assert.NotNil(t, err)
+		}
+
+		_, err := oauth2.NewRefresher(context.Background(), nil)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("StopsOnStop", func(t *testing.T) {
+		fetcher := &shortLivedFetcher{lifetime: 20 * time.Millisecond}
+
+		r, err := oauth2.NewRefresher(context.Background(), oauth2.NewMemoryStore(), oauth2.WithRefreshJitter(0))
+		require.Nil(t, err)
+		require.Nil(t, r.Register("app", fetcher))
+
+		assert.Eventually(t, func() bool { return fetcher.calls() >= 1 }, time.Second, time.Millisecond)
+
+		r.Stop()
+		calls := fetcher.calls()
+
+		time.Sleep(50 * time.Millisecond)
+		assert.Equal(t, calls, fetcher.calls())
+		assert.True(t, errors.Is(r.Register("other", fetcher), oauth2.ErrRefresherStopped))
+	})
+
+	t.Run("StopsOnContextCancel", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		fetcher := &shortLivedFetcher{lifetime: 20 * time.Millisecond}
+
+		r, err := oauth2.NewRefresher(ctx, oauth2.NewMemoryStore())
+		require.Nil(t, err)
+		require.Nil(t, r.Register("app", fetcher))
+
+		cancel()
+		r.Stop()
+		calls := fetcher.calls()
+
+		time.Sleep(50 * time.Millisecond)
+		assert.Equal(t, calls, fetcher.calls())
+		assert.True(t, errors.Is(r.Register("other", fetcher), oauth2.ErrRefresherStopped))
+	})
+
+	t.Run("Unregisters", func(t *testing.T) {
+		fetcher := &shortLivedFetcher{lifetime: 20 * time.Millisecond}
+
+		r, err := oauth2.NewRefresher(context.Background(), oauth2.NewMemoryStore())
+		require.Nil(t, err)
+		defer r.Stop()
+
+		require.Nil(t, r.Register("app", fetcher))
+		assert.Eventually(t, func() bool { return fetcher.calls() >= 1 }, time.Second, time.Millisecond)
+
+		r.Unregister("app")
+		calls := fetcher.calls()
+
+		time.Sleep(50 * time.Millisecond)
+		assert.Equal(t, calls, fetcher.calls())
+	})
+}
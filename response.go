@@ -0,0 +1,90 @@
+package oauth2
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxResponseBytes caps the size of a token response. Token responses are a few kilobytes, so
+// anything larger is not one.
+const maxResponseBytes = 1 << 20
+
+// maxSnippetBytes caps how much of an unexpected body is quoted in errors.
+const maxSnippetBytes = 200
+
+var (
+	// ErrResponseTooLarge is returned when a successful token response is larger than the size
+	// limit. Error responses are truncated instead.
+	ErrResponseTooLarge = errors.New("token response too large")
+	// ErrUnexpectedResponse is returned when a successful token response is not JSON, e.g. an
+	// HTML page from a proxy.
+	ErrUnexpectedResponse = errors.New("unexpected token response")
+)
+
+// readResponseBody reads at most maxResponseBytes of the body, reporting whether there was more.
+// The body is always drained and closed, so its connection can be reused.
+func readResponseBody(res *http.Response) ([]byte, bool, error) {
+	defer drainAndClose(res.Body)
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxResponseBytes+1))
+	if err != nil {
+		return nil, false, err
+	}
+
+	if len(body) > maxResponseBytes {
+		return body[:maxResponseBytes], true, nil
+	}
+
+	return body, false, nil
+}
+
+// isJSONContentType reports whether the Content-Type header is JSON, such as "application/json"
+// or "application/problem+json". A missing header is reported as such, so the caller can fall
+// back to decoding.
+func isJSONContentType(header http.Header) (isJSON bool, present bool) {
+	contentType := header.Get("Content-Type")
+	if contentType == "" {
+		return false, false
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false, true
+	}
+
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"), true
+}
+
+// unexpectedResponseError describes a successful response that is not a token, quoting the
+// beginning of its body.
+func unexpectedResponseError(res *http.Response, body []byte, reason string) error {
+	return fmt.Errorf(
+		"%w: %s, status %d, content type %q, body %q",
+		ErrUnexpectedResponse,
+		reason,
+		res.StatusCode,
+		res.Header.Get("Content-Type"),
+		snippet(body),
+	)
+}
+
+// snippet returns the beginning of body, trimmed and truncated to maxSnippetBytes on a rune
+// boundary.
+func snippet(body []byte) string {
+	s := strings.TrimSpace(string(body))
+	if len(s) <= maxSnippetBytes {
+		return s
+	}
+
+	s = s[:maxSnippetBytes]
+	for len(s) > 0 && !utf8.ValidString(s) {
+		s = s[:len(s)-1]
+	}
+
+	return s + "..."
+}
@@ -0,0 +1,104 @@
+// +build unit
+
+package oauth2_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	oauth2 "github.com/ralucas/go-ebay-oauth2"
+	"github.com/ralucas/go-ebay-oauth2/double"
+)
+
+// closeRecordingBody records whether it was closed.
+type closeRecordingBody struct {
+	io.Reader
+	closed bool
+}
+
+func (b *closeRecordingBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+type bodyHTTPClient struct {
+	statusCode int
+	body       *closeRecordingBody
+}
+
+func (c *bodyHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: c.statusCode,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       c.body,
+	}, nil
+}
+
+func TestTokenResponse(t *testing.T) {
+	client := oauth2.NewClient(testBaseUrl, testClientId, testClientSecret, testRedirectUri)
+
+	htmlPage := "<html><head><title>502 Bad Gateway</title></head><body>" + strings.Repeat("x", 500) + "</body></html>"
+
+	t.Run("ClosesBody", func(t *testing.T) {
+		for _, status := range []int{http.StatusOK, http.StatusBadRequest} {
+			body := &closeRecordingBody{Reader: strings.NewReader(testTokenBody + strings.Repeat(" ", 1024))}
+			client.SetHTTPClient(&bodyHTTPClient{statusCode: status, body: body})
+
+			_, _ = client.ClientCredentials(testScopes).AccessToken()
+
+			assert.True(t, body.closed, fmt.Sprintf("status %d", status))
+		}
+	})
+
+	t.Run("RejectsNonJSONContentType", func(t *testing.T) {
+		client.SetHTTPClient(&double.StubHTTPClient{StatusCode: http.StatusOK, ContentType: "text/html; charset=utf-8", Body: htmlPage})
+
+		token, err := client.ClientCredentials(testScopes).AccessToken()
+
+		assert.Nil(t, token)
+		assert.True(t, errors.Is(err, oauth2.ErrUnexpectedResponse), fmt.Sprintf("%v", err))
+		assert.Contains(t, err.Error(), "502 Bad Gateway")
+		assert.Contains(t, err.Error(), "text/html")
+		assert.Less(t, len(err.Error()), 400)
+	})
+
+	t.Run("AcceptsJSONWithoutContentType", func(t *testing.T) {
+		client.SetHTTPClient(&double.StubHTTPClient{StatusCode: http.StatusOK, Body: testTokenBody})
+
+		token, err := client.ClientCredentials(testScopes).AccessToken()
+
+		require.Nil(t, err, fmt.Sprintf("%v", err))
+		assert.NotEmpty(t, token.AccessToken)
+	})
+
+	t.Run("QuotesNonJSONErrorBody", func(t *testing.T) {
+		client.SetHTTPClient(&double.StubHTTPClient{StatusCode: http.StatusBadGateway, ContentType: "text/html", Body: htmlPage})
+
+		_, err := client.ClientCredentials(testScopes).AccessToken()
+
+		var te *oauth2.TokenError
+		require.True(t, errors.As(err, &te))
+		assert.Equal(t, http.StatusBadGateway, te.StatusCode)
+		assert.Contains(t, err.Error(), "<title>502 Bad Gateway</title>")
+		assert.Contains(t, err.Error(), "...")
+	})
+
+	t.Run("RejectsTooLargeResponse", func(t *testing.T) {
+		body := `{"access_token":"` + strings.Repeat("x", 2<<20) + `"}`
+		client.SetHTTPClient(&double.StubHTTPClient{StatusCode: http.StatusOK, ContentType: "application/json", Body: body})
+
+		token, err := client.ClientCredentials(testScopes).AccessToken()
+
+		assert.Nil(t, token)
+		assert.True(t, errors.Is(err, oauth2.ErrResponseTooLarge), fmt.Sprintf("%v", err))
+		assert.Contains(t, err.Error(), `{\"access_token\":\"xxx`)
+		assert.Less(t, len(err.Error()), 500)
+	})
+}
@@ -0,0 +1,183 @@
+package oauth2
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy configures how token requests are retried after transient failures: network
+// errors, 429 Too Many Requests, and 5xx responses from the token endpoint. The zero value
+// makes a single attempt.
+//
+// Authorization codes are single-use, so authorization code exchanges are only retried when
+// the request certainly did not reach eBay: when the connection could not be established, or
+// when eBay rejected the request with 429 Too Many Requests.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first one.
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry. It doubles with each retry.
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between attempts. A Retry-After longer than MaxDelay is not
+	// waited for, and the failure is returned instead. Zero means no cap.
+	MaxDelay time.Duration
+	// Jitter is the fraction, between 0 and 1, of each delay that is randomized.
+	Jitter float64
+}
+
+// DefaultRetryPolicy is a RetryPolicy suitable for most applications.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    10 * time.Second,
+	Jitter:      0.2,
+}
+
+// backoff returns the delay before the retry following attempt, which starts at 1.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempt && (p.MaxDelay <= 0 || delay < p.MaxDelay); i++ {
+		delay *= 2
+	}
+
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+
+	if p.Jitter > 0 {
+		delay -= time.Duration(p.Jitter * rand.Float64() * float64(delay))
+	}
+
+	return delay
+}
+
+// retryDelay returns how long to wait before retrying the attempt that got res or err, and
+// whether to retry at all.
+func (p RetryPolicy) retryDelay(
+	attempt int,
+	idempotent bool,
+	res *http.Response,
+	err error,
+	now time.Time,
+) (time.Duration, bool) {
+	if attempt >= p.MaxAttempts {
+		return 0, false
+	}
+
+	if err != nil {
+		if !isRetryableError(err, idempotent) {
+			return 0, false
+		}
+
+		return p.backoff(attempt), true
+	}
+
+	if !isRetryableStatus(res.StatusCode, idempotent) {
+		return 0, false
+	}
+
+	delay := p.backoff(attempt)
+
+	if retryAfter, ok := parseRetryAfter(res.Header.Get("Retry-After"), now); ok {
+		if p.MaxDelay > 0 && retryAfter > p.MaxDelay {
+			return 0, false
+		}
+
+		if retryAfter > delay {
+			delay = retryAfter
+		}
+	}
+
+	return delay, true
+}
+
+// isRetryableError reports whether a request that failed with err can be retried: only network
+// failures are, not permanent ones such as an invalid certificate or URL. Requests that are not
+// idempotent are only retried if the connection could not be established.
+func isRetryableError(err error, idempotent bool) bool {
+	if isContextError(err) {
+		return false
+	}
+
+	if !idempotent {
+		var opErr *net.OpError
+
+		return errors.As(err, &opErr) && opErr.Op == "dial"
+	}
+
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) || errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+
+	// *url.Error, which wraps every error of an http.Client, is itself a net.Error.
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+
+	var netErr net.Error
+
+	return errors.As(err, &netErr)
+}
+
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// isRetryableStatus reports whether a request that got a response with statusCode can be
+// retried. Requests that are not idempotent are only retried if they were rate limited.
+func isRetryableStatus(statusCode int, idempotent bool) bool {
+	switch statusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusRequestTimeout,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return idempotent
+	default:
+		return false
+	}
+}
+
+// parseRetryAfter parses a Retry-After header given either in seconds or as an HTTP date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		if delay := date.Sub(now); delay > 0 {
+			return delay, true
+		}
+
+		return 0, true
+	}
+
+	return 0, false
+}
+
+// sleepContext waits for d, returning early with the context error if ctx is done first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
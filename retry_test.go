@@ -0,0 +1,252 @@
+// +build unit
+
+package oauth2_test
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	oauth2 "github.com/ralucas/go-ebay-oauth2"
+	"github.com/ralucas/go-ebay-oauth2/double"
+)
+
+var (
+	testTokenBody = `{"access_token":"test-token","expires_in":7200,"token_type":"Application Access Token"}`
+
+	testRetryPolicy = oauth2.RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   time.Millisecond,
+		MaxDelay:    2 * time.Second,
+		Jitter:      0.5,
+	}
+
+	testResetErr = &url.Error{Op: "Post", URL: testBaseUrl, Err: &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}}
+	testDialErr  = &url.Error{Op: "Post", URL: testBaseUrl, Err: &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}}
+)
+
+func TestRetryPolicy(t *testing.T) {
+	client, err := oauth2.New(testClientId, testClientSecret, testRuName, oauth2.WithRetryPolicy(testRetryPolicy))
+	require.Nil(t, err)
+
+	exchange := func(client oauth2.Oauth2Client) (*oauth2.AccessToken, error) {
+		testURL, err := url.Parse(fmt.Sprintf("https://test.com/redirect?code=%s", testCode))
+		require.Nil(t, err)
+
+		return client.AuthorizationCode(testScopes).ExchangeAuthorizationForToken(testURL)
+	}
+
+	t.Run("RetriesTransientFailures", func(t *testing.T) {
+		httpClient := &double.SequenceHTTPClient{Responses: []double.StubResponse{
+			{Err: testResetErr},
+			{StatusCode: http.StatusServiceUnavailable, Body: "unavailable"},
+			{StatusCode: http.StatusOK, Body: testTokenBody},
+		}}
+		httpClient.Reset()
+		client.SetHTTPClient(httpClient)
+
+		token, err := client.ClientCredentials(testScopes).AccessToken()
+		require.Nil(t, err, fmt.Sprintf("%v", err))
+
+		assert.Equal(t, "test-token", token.AccessToken)
+		require.Equal(t, 3, httpClient.CallCount("Do"))
+
+		// Every attempt sends the whole request body.
+		for _, call := range httpClient.Calls("Do") {
+			body, err := io.ReadAll(call.Arguments()[0].(*http.Request).Body)
+			require.Nil(t, err)
+
+			assert.Contains(t, string(body), "grant_type=client_credentials")
+		}
+	})
+
+	t.Run("StopsAfterMaxAttempts", func(t *testing.T) {
+		httpClient := &double.SequenceHTTPClient{Responses: []double.StubResponse{
+			{StatusCode: http.StatusInternalServerError, Body: "error"},
+		}}
+		httpClient.Reset()
+		client.SetHTTPClient(httpClient)
+
+		_, err := client.ClientCredentials(testScopes).AccessToken()
+
+		var te *oauth2.TokenError
+		require.True(t, errors.As(err, &te), fmt.Sprintf("%v", err))
+		assert.Equal(t, http.StatusInternalServerError, te.StatusCode)
+		assert.Equal(t, 3, httpClient.CallCount("Do"))
+	})
+
+	t.Run("RetriesUnexpectedEOF", func(t *testing.T) {
+		httpClient := &double.SequenceHTTPClient{Responses: []double.StubResponse{
+			{Err: &url.Error{Op: "Post", URL: testBaseUrl, Err: io.ErrUnexpectedEOF}},
+			{StatusCode: http.StatusOK, Body: testTokenBody},
+		}}
+		httpClient.Reset()
+		client.SetHTTPClient(httpClient)
+
+		_, err := client.ClientCredentials(testScopes).AccessToken()
+		require.Nil(t, err, fmt.Sprintf("%v", err))
+
+		assert.Equal(t, 2, httpClient.CallCount("Do"))
+	})
+
+	t.Run("DoesNotRetryPermanentErrors", func(t *testing.T) {
+		for _, permanentErr := range []error{
+			&url.Error{Op: "Post", URL: testBaseUrl, Err: x509.UnknownAuthorityError{}},
+			&url.Error{Op: "Post", URL: testBaseUrl, Err: errors.New(`unsupported protocol scheme "ftp"`)},
+		} {
+			httpClient := &double.SequenceHTTPClient{Responses: []double.StubResponse{
+				{Err: permanentErr},
+				{StatusCode: http.StatusOK, Body: testTokenBody},
+			}}
+			httpClient.Reset()
+			client.SetHTTPClient(httpClient)
+
+			_, err := client.ClientCredentials(testScopes).AccessToken()
+
+			assert.NotNil(t, err)
+			assert.Equal(t, 1, httpClient.CallCount("Do"), fmt.Sprintf("%v", permanentErr))
+		}
+	})
+
+	t.Run("DoesNotRetryClientErrors", func(t *testing.T) {
+		httpClient := &double.SequenceHTTPClient{Responses: []double.StubResponse{
+			{StatusCode: http.StatusBadRequest, Body: `{"error":"invalid_scope"}`},
+		}}
+		httpClient.Reset()
+		client.SetHTTPClient(httpClient)
+
+		_, err := client.ClientCredentials(testScopes).AccessToken()
+
+		assert.True(t, errors.Is(err, oauth2.ErrInvalidScope), fmt.Sprintf("%v", err))
+		assert.Equal(t, 1, httpClient.CallCount("Do"))
+	})
+
+	t.Run("WaitsForRetryAfter", func(t *testing.T) {
+		httpClient := &double.SequenceHTTPClient{Responses: []double.StubResponse{
+			{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"1"}}},
+			{StatusCode: http.StatusOK, Body: testTokenBody},
+		}}
+		httpClient.Reset()
+		client.SetHTTPClient(httpClient)
+
+		start := time.Now()
+
+		_, err := client.ClientCredentials(testScopes).AccessToken()
+		require.Nil(t, err, fmt.Sprintf("%v", err))
+
+		assert.True(t, time.Since(start) >= time.Second)
+		assert.Equal(t, 2, httpClient.CallCount("Do"))
+	})
+
+	t.Run("DoublesDelayWithoutMaxDelay", func(t *testing.T) {
+		httpClient := &double.SequenceHTTPClient{Responses: []double.StubResponse{
+			{StatusCode: http.StatusServiceUnavailable, Body: "unavailable"},
+			{StatusCode: http.StatusServiceUnavailable, Body: "unavailable"},
+			{StatusCode: http.StatusServiceUnavailable, Body: "unavailable"},
+			{StatusCode: http.StatusOK, Body: testTokenBody},
+		}}
+		httpClient.Reset()
+
+		uncapped, err := oauth2.New(
+			testClientId,
+			testClientSecret,
+			testRuName,
+			oauth2.WithHTTPClient(httpClient),
+			oauth2.WithRetryPolicy(oauth2.RetryPolicy{MaxAttempts: 4, BaseDelay: 20 * time.Millisecond}),
+		)
+		require.Nil(t, err)
+
+		start := time.Now()
+
+		_, err = uncapped.ClientCredentials(testScopes).AccessToken()
+		require.Nil(t, err, fmt.Sprintf("%v", err))
+
+		// 20ms, 40ms and 80ms, rather than 20ms each time.
+		assert.True(t, time.Since(start) >= 140*time.Millisecond)
+		assert.Equal(t, 4, httpClient.CallCount("Do"))
+	})
+
+	t.Run("GivesUpOnRetryAfterBeyondMaxDelay", func(t *testing.T) {
+		httpClient := &double.SequenceHTTPClient{Responses: []double.StubResponse{
+			{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"3600"}}},
+			{StatusCode: http.StatusOK, Body: testTokenBody},
+		}}
+		httpClient.Reset()
+		client.SetHTTPClient(httpClient)
+
+		_, err := client.ClientCredentials(testScopes).AccessToken()
+
+		var te *oauth2.TokenError
+		require.True(t, errors.As(err, &te), fmt.Sprintf("%v", err))
+		assert.Equal(t, http.StatusTooManyRequests, te.StatusCode)
+		assert.Equal(t, 1, httpClient.CallCount("Do"))
+	})
+
+	t.Run("DoesNotRetryAcceptedCodeExchange", func(t *testing.T) {
+		httpClient := &double.SequenceHTTPClient{Responses: []double.StubResponse{
+			{Err: testResetErr},
+			{StatusCode: http.StatusOK, Body: testTokenBody},
+		}}
+		httpClient.Reset()
+		client.SetHTTPClient(httpClient)
+
+		_, err := exchange(client)
+
+		assert.NotNil(t, err)
+		assert.Equal(t, 1, httpClient.CallCount("Do"))
+
+		httpClient = &double.SequenceHTTPClient{Responses: []double.StubResponse{
+			{StatusCode: http.StatusBadGateway},
+			{StatusCode: http.StatusOK, Body: testTokenBody},
+		}}
+		httpClient.Reset()
+		client.SetHTTPClient(httpClient)
+
+		_, err = exchange(client)
+
+		assert.NotNil(t, err)
+		assert.Equal(t, 1, httpClient.CallCount("Do"))
+	})
+
+	t.Run("RetriesUnsentCodeExchange", func(t *testing.T) {
+		httpClient := &double.SequenceHTTPClient{Responses: []double.StubResponse{
+			{Err: testDialErr},
+			{StatusCode: http.StatusTooManyRequests},
+			{StatusCode: http.StatusOK, Body: testTokenBody},
+		}}
+		httpClient.Reset()
+		client.SetHTTPClient(httpClient)
+
+		token, err := exchange(client)
+		require.Nil(t, err, fmt.Sprintf("%v", err))
+
+		assert.Equal(t, "test-token", token.AccessToken)
+		assert.Equal(t, 3, httpClient.CallCount("Do"))
+	})
+
+	t.Run("DoesNotRetryByDefault", func(t *testing.T) {
+		client := oauth2.NewClient(testBaseUrl, testClientId, testClientSecret, testRuName)
+
+		httpClient := &double.SequenceHTTPClient{Responses: []double.StubResponse{
+			{StatusCode: http.StatusServiceUnavailable},
+			{StatusCode: http.StatusOK, Body: testTokenBody},
+		}}
+		httpClient.Reset()
+		client.SetHTTPClient(httpClient)
+
+		_, err := client.ClientCredentials(testScopes).AccessToken()
+
+		assert.NotNil(t, err)
+		assert.Equal(t, 1, httpClient.CallCount("Do"))
+	})
+}
@@ -0,0 +1,105 @@
+package oauth2
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+var (
+	// ErrRuNameIsURL is returned when a URL is given where the RuName of the application is
+	// expected.
+	ErrRuNameIsURL = errors.New("redirect URI must be a RuName, not a URL")
+	// ErrCallbackURLMismatch is returned when the url of the redirect back from the consent page
+	// does not match the accept URL of the RuName.
+	ErrCallbackURLMismatch = errors.New("callback url does not match the accept URL")
+)
+
+// RuName is the eBay Redirect URL name of an application, as created in the developer portal. It
+// is sent as the redirect_uri of the flows, and stands for the URLs eBay redirects the user to
+// from the consent page.
+type RuName struct {
+	// Name is the RuName, e.g. "My_Company-MyApp-PRD-1a2b3c4d5-6e7f8a9b".
+	Name string
+	// AcceptURL is the "auth accepted URL" configured for the RuName. When set, the url of the
+	// redirect must match its host and path. Optional.
+	AcceptURL string
+	// DeclineURL is the "auth declined URL" configured for the RuName. Optional.
+	DeclineURL string
+}
+
+// validate checks that the name is a RuName and that the URLs, if any, are https URLs.
+func (r RuName) validate() error {
+	if r.Name == "" {
+		return errors.New("missing redirect URI")
+	}
+
+	if looksLikeURL(r.Name) {
+		return fmt.Errorf("%w: %q", ErrRuNameIsURL, r.Name)
+	}
+
+	if r.AcceptURL != "" {
+		if err := validateHTTPSURL("accept URL", r.AcceptURL); err != nil {
+			return err
+		}
+	}
+
+	if r.DeclineURL != "" {
+		if err := validateHTTPSURL("decline URL", r.DeclineURL); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkCallbackURL checks that the host and path of the redirect url match those of the accept
+// URL. The host is only checked when reqURL has one; the URL of a server request has only a path,
+// so it is checked with callbackURL.
+func (r RuName) checkCallbackURL(reqURL *url.URL) error {
+	if r.AcceptURL == "" {
+		return nil
+	}
+
+	accept, err := url.Parse(r.AcceptURL)
+	if err != nil {
+		return fmt.Errorf("invalid accept URL: %w", err)
+	}
+
+	if reqURL.Host != "" && !strings.EqualFold(reqURL.Host, accept.Host) {
+		return fmt.Errorf("%w, got host %s, expected %s", ErrCallbackURLMismatch, reqURL.Host, accept.Host)
+	}
+
+	if callbackPath(reqURL) != callbackPath(accept) {
+		return fmt.Errorf("%w, got path %s, expected %s", ErrCallbackURLMismatch, callbackPath(reqURL), callbackPath(accept))
+	}
+
+	return nil
+}
+
+// callbackURL returns the url of the server request r, with the host it was sent to, so that
+// checkCallbackURL checks its host too. Behind a proxy that rewrites the Host header, the accept
+// URL must then be the one the proxy forwards to.
+func callbackURL(r *http.Request) *url.URL {
+	u := *r.URL
+	if u.Host == "" {
+		u.Host = r.Host
+	}
+
+	return &u
+}
+
+func callbackPath(u *url.URL) string {
+	if u.Path == "" {
+		return "/"
+	}
+
+	return u.Path
+}
+
+// looksLikeURL reports whether s is a URL or a path. RuNames have neither colons nor slashes.
+func looksLikeURL(s string) bool {
+	return strings.ContainsAny(s, ":/")
+}
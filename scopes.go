@@ -0,0 +1,216 @@
+package oauth2
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ScopeBase is the eBay scope that grants basic access to the APIs, and the prefix of every
+// other eBay scope.
+const ScopeBase = "https://api.ebay.com/oauth/api_scope"
+
+// eBay scopes, as documented for the Buy, Sell and Commerce APIs.
+const (
+	ScopeAPI = ScopeBase
+
+	ScopeBuyDeal                  = ScopeBase + "/buy.deal"
+	ScopeBuyGuestOrder            = ScopeBase + "/buy.guest.order"
+	ScopeBuyItemBulk              = ScopeBase + "/buy.item.bulk"
+	ScopeBuyItemFeed              = ScopeBase + "/buy.item.feed"
+	ScopeBuyMarketing             = ScopeBase + "/buy.marketing"
+	ScopeBuyMarketplaceInsights   = ScopeBase + "/buy.marketplace.insights"
+	ScopeBuyOfferAuction          = ScopeBase + "/buy.offer.auction"
+	ScopeBuyOrderReadonly         = ScopeBase + "/buy.order.readonly"
+	ScopeBuyProductFeed           = ScopeBase + "/buy.product.feed"
+	ScopeBuyProxyGuestOrder       = ScopeBase + "/buy.proxy.guest.order"
+	ScopeBuyShoppingCart          = ScopeBase + "/buy.shopping.cart"
+	ScopeCommerceCatalogReadonly  = ScopeBase + "/commerce.catalog.readonly"
+	ScopeCommerceIdentityReadonly = ScopeBase + "/commerce.identity.readonly"
+
+	ScopeCommerceNotificationSubscription         = ScopeBase + "/commerce.notification.subscription"
+	ScopeCommerceNotificationSubscriptionReadonly = ScopeBase + "/commerce.notification.subscription.readonly"
+
+	ScopeSellAccount                     = ScopeBase + "/sell.account"
+	ScopeSellAccountReadonly             = ScopeBase + "/sell.account.readonly"
+	ScopeSellAnalyticsReadonly           = ScopeBase + "/sell.analytics.readonly"
+	ScopeSellFinances                    = ScopeBase + "/sell.finances"
+	ScopeSellFulfillment                 = ScopeBase + "/sell.fulfillment"
+	ScopeSellFulfillmentReadonly         = ScopeBase + "/sell.fulfillment.readonly"
+	ScopeSellInventory                   = ScopeBase + "/sell.inventory"
+	ScopeSellInventoryReadonly           = ScopeBase + "/sell.inventory.readonly"
+	ScopeSellItem                        = ScopeBase + "/sell.item"
+	ScopeSellItemDraft                   = ScopeBase + "/sell.item.draft"
+	ScopeSellMarketing                   = ScopeBase + "/sell.marketing"
+	ScopeSellMarketingReadonly           = ScopeBase + "/sell.marketing.readonly"
+	ScopeSellMarketplaceInsightsReadonly = ScopeBase + "/sell.marketplace.insights.readonly"
+	ScopeSellPaymentDispute              = ScopeBase + "/sell.payment.dispute"
+	ScopeSellReputation                  = ScopeBase + "/sell.reputation"
+	ScopeSellReputationReadonly          = ScopeBase + "/sell.reputation.readonly"
+	ScopeSellStores                      = ScopeBase + "/sell.stores"
+	ScopeSellStoresReadonly              = ScopeBase + "/sell.stores.readonly"
+)
+
+var (
+	// ErrUnknownScope is returned when a short scope name is not in the scope catalog.
+	ErrUnknownScope = errors.New("unknown scope")
+	// ErrUserScope is returned when a scope that is only granted to user tokens is requested
+	// in the client credentials grant.
+	ErrUserScope = errors.New("scope is only granted to user tokens")
+)
+
+// Scope describes an eBay scope and the kinds of tokens it can be granted to.
+type Scope struct {
+	// URL is the full scope, as sent to eBay.
+	URL string
+	// AppToken is true if the scope can be granted to application tokens, from the client
+	// credentials grant.
+	AppToken bool
+	// UserToken is true if the scope can be granted to user tokens, from the authorization
+	// code grant.
+	UserToken bool
+}
+
+// Name returns the short name of the scope, e.g. "sell.inventory", or "api_scope" for ScopeAPI.
+func (s Scope) Name() string {
+	if s.URL == ScopeBase {
+		return "api_scope"
+	}
+
+	return strings.TrimPrefix(s.URL, ScopeBase+"/")
+}
+
+func appScope(url string) Scope  { return Scope{URL: url, AppToken: true} }
+func userScope(url string) Scope { return Scope{URL: url, UserToken: true} }
+
+// scopeCatalog holds the documented eBay scopes by URL.
+var scopeCatalog = catalog(
+	Scope{URL: ScopeAPI, AppToken: true, UserToken: true},
+	Scope{URL: ScopeCommerceCatalogReadonly, AppToken: true, UserToken: true},
+
+	appScope(ScopeBuyDeal),
+	appScope(ScopeBuyGuestOrder),
+	appScope(ScopeBuyItemBulk),
+	appScope(ScopeBuyItemFeed),
+	appScope(ScopeBuyMarketing),
+	appScope(ScopeBuyMarketplaceInsights),
+	appScope(ScopeBuyProductFeed),
+	appScope(ScopeBuyProxyGuestOrder),
+
+	userScope(ScopeBuyOfferAuction),
+	userScope(ScopeBuyOrderReadonly),
+	userScope(ScopeBuyShoppingCart),
+	userScope(ScopeCommerceIdentityReadonly),
+	userScope(ScopeCommerceNotificationSubscription),
+	userScope(ScopeCommerceNotificationSubscriptionReadonly),
+	userScope(ScopeSellAccount),
+	userScope(ScopeSellAccountReadonly),
+	userScope(ScopeSellAnalyticsReadonly),
+	userScope(ScopeSellFinances),
+	userScope(ScopeSellFulfillment),
+	userScope(ScopeSellFulfillmentReadonly),
+	userScope(ScopeSellInventory),
+	userScope(ScopeSellInventoryReadonly),
+	userScope(ScopeSellItem),
+	userScope(ScopeSellItemDraft),
+	userScope(ScopeSellMarketing),
+	userScope(ScopeSellMarketingReadonly),
+	userScope(ScopeSellMarketplaceInsightsReadonly),
+	userScope(ScopeSellPaymentDispute),
+	userScope(ScopeSellReputation),
+	userScope(ScopeSellReputationReadonly),
+	userScope(ScopeSellStores),
+	userScope(ScopeSellStoresReadonly),
+)
+
+func catalog(scopes ...Scope) map[string]Scope {
+	c := make(map[string]Scope, len(scopes))
+	for _, s := range scopes {
+		c[s.URL] = s
+	}
+
+	return c
+}
+
+// LookupScope returns the catalog entry of a scope given by its URL or short name.
+func LookupScope(name string) (Scope, bool) {
+	s, ok := scopeCatalog[scopeURL(name)]
+
+	return s, ok
+}
+
+// ExpandScopes returns the scopes with short names, like "sell.inventory", expanded to their
+// full URLs. Short names that are not in the catalog return ErrUnknownScope. Full URLs are
+// returned as they are, so scopes newer than the catalog can still be used.
+func ExpandScopes(scopes []string) ([]string, error) {
+	expanded := make([]string, 0, len(scopes))
+
+	for _, name := range scopes {
+		url := scopeURL(name)
+
+		if _, ok := scopeCatalog[url]; !ok && !isScopeURL(name) {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownScope, name)
+		}
+
+		expanded = append(expanded, url)
+	}
+
+	return expanded, nil
+}
+
+// UnionScopes expands the scopes of each set like ExpandScopes, and returns every scope once, in
+// the order they first appear.
+func UnionScopes(sets ...[]string) ([]string, error) {
+	var union []string
+
+	seen := make(map[string]bool)
+
+	for _, set := range sets {
+		expanded, err := ExpandScopes(set)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, url := range expanded {
+			if !seen[url] {
+				seen[url] = true
+				union = append(union, url)
+			}
+		}
+	}
+
+	return union, nil
+}
+
+// expandAppScopes expands the scopes like ExpandScopes, and returns ErrUserScope if one of
+// them is known to be granted only to user tokens.
+func expandAppScopes(scopes []string) ([]string, error) {
+	expanded, err := ExpandScopes(scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, url := range expanded {
+		if s, ok := scopeCatalog[url]; ok && !s.AppToken {
+			return nil, fmt.Errorf("%w: %s", ErrUserScope, url)
+		}
+	}
+
+	return expanded, nil
+}
+
+// scopeURL returns the full URL of a scope given by its URL or short name.
+func scopeURL(name string) string {
+	switch {
+	case isScopeURL(name):
+		return name
+	case name == "api_scope":
+		return ScopeBase
+	default:
+		return ScopeBase + "/" + name
+	}
+}
+
+func isScopeURL(name string) bool {
+	return strings.HasPrefix(name, "https://")
+}
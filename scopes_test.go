@@ -0,0 +1,136 @@
+// +build unit
+
+package oauth2_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	oauth2 "github.com/ralucas/go-ebay-oauth2"
+	"github.com/ralucas/go-ebay-oauth2/double"
+)
+
+func TestExpandScopes(t *testing.T) {
+	t.Run("ExpandsShortNames", func(t *testing.T) {
+		scopes, err := oauth2.ExpandScopes([]string{"api_scope", "sell.inventory", oauth2.ScopeSellFulfillment})
+		require.Nil(t, err, fmt.Sprintf("%v", err))
+
+		assert.Equal(t, []string{
+			"https://api.ebay.com/oauth/api_scope",
+			"https://api.ebay.com/oauth/api_scope/sell.inventory",
+			"https://api.ebay.com/oauth/api_scope/sell.fulfillment",
+		}, scopes)
+	})
+
+	t.Run("ErrorsOnUnknownShortName", func(t *testing.T) {
+		_, err := oauth2.ExpandScopes([]string{"sell.inventroy"})
+
+		assert.True(t, errors.Is(err, oauth2.ErrUnknownScope), fmt.Sprintf("%v", err))
+		assert.Contains(t, err.Error(), "sell.inventroy")
+	})
+
+	t.Run("KeepsUnknownURLs", func(t *testing.T) {
+		scopes, err := oauth2.ExpandScopes([]string{"https://api.ebay.com/oauth/api_scope/sell.new.api"})
+		require.Nil(t, err, fmt.Sprintf("%v", err))
+
+		assert.Equal(t, []string{"https://api.ebay.com/oauth/api_scope/sell.new.api"}, scopes)
+	})
+}
+
+func TestUnionScopes(t *testing.T) {
+	t.Run("KeepsFirstOccurrence", func(t *testing.T) {
+		union, err := oauth2.UnionScopes(
+			[]string{oauth2.ScopeSellInventory, oauth2.ScopeSellFulfillment},
+			[]string{"sell.fulfillment", "sell.finances"},
+		)
+		require.Nil(t, err, fmt.Sprintf("%v", err))
+
+		assert.Equal(t, []string{oauth2.ScopeSellInventory, oauth2.ScopeSellFulfillment, oauth2.ScopeSellFinances}, union)
+	})
+
+	t.Run("ErrorsOnUnknownScope", func(t *testing.T) {
+		_, err := oauth2.UnionScopes(nil, []string{"sell.inventroy"})
+
+		assert.True(t, errors.Is(err, oauth2.ErrUnknownScope), fmt.Sprintf("%v", err))
+	})
+}
+
+func TestLookupScope(t *testing.T) {
+	s, ok := oauth2.LookupScope("commerce.identity.readonly")
+	require.True(t, ok)
+
+	assert.Equal(t, oauth2.ScopeCommerceIdentityReadonly, s.URL)
+	assert.Equal(t, "commerce.identity.readonly", s.Name())
+	assert.True(t, s.UserToken)
+	assert.False(t, s.AppToken)
+
+	s, ok = oauth2.LookupScope(oauth2.ScopeAPI)
+	require.True(t, ok)
+
+	assert.Equal(t, "api_scope", s.Name())
+	assert.True(t, s.UserToken)
+	assert.True(t, s.AppToken)
+
+	_, ok = oauth2.LookupScope("sell.unknown")
+	assert.False(t, ok)
+}
+
+func TestScopes_Flows(t *testing.T) {
+	client := oauth2.NewClient(testBaseUrl, testClientId, testClientSecret, testRedirectUri)
+
+	t.Run("ClientCredentialsRejectsUserScope", func(t *testing.T) {
+		spyHttpClient := &double.SpyHTTPClient{}
+		spyHttpClient.Reset()
+
+		client.SetHTTPClient(spyHttpClient)
+
+		_, err := client.ClientCredentials([]string{"api_scope", "sell.inventory"}).AccessToken()
+
+		assert.True(t, errors.Is(err, oauth2.ErrUserScope), fmt.Sprintf("%v", err))
+		assert.Equal(t, 0, spyHttpClient.CallCount("Do"))
+	})
+
+	t.Run("ClientCredentialsSendsExpandedScopes", func(t *testing.T) {
+		spyHttpClient := &double.SpyHTTPClient{}
+		spyHttpClient.Reset()
+
+		client.SetHTTPClient(spyHttpClient)
+
+		_, err := client.ClientCredentials([]string{"api_scope", "buy.item.feed"}).AccessToken()
+		require.Nil(t, err, fmt.Sprintf("%v", err))
+
+		callReq := spyHttpClient.Calls("Do")[0].Arguments()[0].(*http.Request)
+
+		body, err := io.ReadAll(callReq.Body)
+		require.Nil(t, err)
+
+		values, err := url.ParseQuery(string(body))
+		require.Nil(t, err)
+
+		assert.Equal(t, oauth2.ScopeAPI+" "+oauth2.ScopeBuyItemFeed, values.Get(oauth2.FieldScope))
+	})
+
+	t.Run("AuthorizationCodeExpandsScopes", func(t *testing.T) {
+		u, err := client.AuthorizationCode([]string{"sell.inventory", "commerce.identity.readonly"}).GrantApplicationAccessURL()
+		require.Nil(t, err, fmt.Sprintf("%v", err))
+
+		assert.Equal(
+			t,
+			oauth2.ScopeSellInventory+" "+oauth2.ScopeCommerceIdentityReadonly,
+			u.Query().Get(oauth2.FieldScope),
+		)
+	})
+
+	t.Run("AuthorizationCodeRejectsUnknownScope", func(t *testing.T) {
+		_, err := client.AuthorizationCode([]string{"sell.inventroy"}).GrantApplicationAccessURL()
+
+		assert.True(t, errors.Is(err, oauth2.ErrUnknownScope), fmt.Sprintf("%v", err))
+	})
+}
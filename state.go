@@ -0,0 +1,252 @@
+package oauth2
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultStateTTL is how long an authorization attempt is valid when no TTL is given.
+const DefaultStateTTL = 10 * time.Minute
+
+// ErrInvalidState is returned when the state of a redirect is unknown, expired, or was
+// already consumed.
+var ErrInvalidState = errors.New("invalid state")
+
+// AuthState is the state of a single authorization attempt. Its Value is sent as the "state"
+// parameter, and is validated and consumed exactly once when the user is redirected back.
+type AuthState struct {
+	// Value is the random state parameter.
+	Value string `json:"value"`
+	// Metadata is arbitrary data of the attempt, such as the return URL or the user ID.
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// ExpiresAt is when the attempt expires.
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// StateStore keeps the states of authorization attempts between the redirect to the consent
+// page and the redirect back. The request and response writer are those of the redirects, for
+// stores that keep the state with the user agent.
+type StateStore interface {
+	// Save saves the state.
+	Save(w http.ResponseWriter, r *http.Request, state *AuthState) error
+	// Consume returns and removes the unexpired state with the value, or ErrInvalidState.
+	Consume(w http.ResponseWriter, r *http.Request, value string) (*AuthState, error)
+}
+
+// NewAuthState returns an AuthState with a cryptographically random value, expiring after ttl.
+func NewAuthState(ttl time.Duration, metadata map[string]string) (*AuthState, error) {
+	if ttl <= 0 {
+		ttl = DefaultStateTTL
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+
+	return &AuthState{
+		Value:     base64.RawURLEncoding.EncodeToString(b),
+		Metadata:  metadata,
+		ExpiresAt: time.Now().Add(ttl),
+	}, nil
+}
+
+type stateContextKey struct{}
+
+// ContextWithState returns a copy of ctx carrying the state.
+func ContextWithState(ctx context.Context, state *AuthState) context.Context {
+	return context.WithValue(ctx, stateContextKey{}, state)
+}
+
+// StateFromContext returns the state carried by ctx, as set by CallbackHandler.
+func StateFromContext(ctx context.Context) (*AuthState, bool) {
+	state, ok := ctx.Value(stateContextKey{}).(*AuthState)
+
+	return state, ok
+}
+
+// MemoryStateStore is a StateStore that keeps states in memory. It is safe for concurrent use,
+// but states are not shared between processes.
+type MemoryStateStore struct {
+	mu     sync.Mutex
+	states map[string]*AuthState
+}
+
+// NewMemoryStateStore returns an empty MemoryStateStore.
+func NewMemoryStateStore() *MemoryStateStore {
+	return &MemoryStateStore{states: make(map[string]*AuthState)}
+}
+
+// Save saves the state, and removes expired states.
+func (m *MemoryStateStore) Save(w http.ResponseWriter, r *http.Request, state *AuthState) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for value, s := range m.states {
+		if !now.Before(s.ExpiresAt) {
+			delete(m.states, value)
+		}
+	}
+
+	m.states[state.Value] = state
+
+	return nil
+}
+
+// Consume returns and removes the unexpired state with the value, or ErrInvalidState.
+func (m *MemoryStateStore) Consume(w http.ResponseWriter, r *http.Request, value string) (*AuthState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, ok := m.states[value]
+	if !ok {
+		return nil, ErrInvalidState
+	}
+
+	delete(m.states, value)
+
+	if !time.Now().Before(state.ExpiresAt) {
+		return nil, ErrInvalidState
+	}
+
+	return state, nil
+}
+
+// DefaultStateCookieName is the prefix of the cookies set by CookieStateStore.
+const DefaultStateCookieName = "ebay_oauth2_state"
+
+// MinCookieStateSecretLength is the minimum length, in bytes, of the secret of a CookieStateStore.
+const MinCookieStateSecretLength = 32
+
+// CookieStateStore is a StateStore that keeps each state in an HMAC-signed cookie of the user
+// agent, so no server-side storage is needed. The cookie is cleared when the state is consumed.
+type CookieStateStore struct {
+	secret []byte
+
+	// Name is the prefix of the cookie names. Defaults to DefaultStateCookieName.
+	Name string
+	// Path is the path of the cookies. Defaults to "/".
+	Path string
+	// Insecure allows the cookies to be sent over plain HTTP, for local development.
+	Insecure bool
+}
+
+// NewCookieStateStore returns a CookieStateStore signing its cookies with secret, which must be
+// at least MinCookieStateSecretLength random bytes.
+func NewCookieStateStore(secret []byte) (*CookieStateStore, error) {
+	if len(secret) < MinCookieStateSecretLength {
+		return nil, fmt.Errorf("cookie state secret must be at least %d bytes, got %d", MinCookieStateSecretLength, len(secret))
+	}
+
+	return &CookieStateStore{secret: append([]byte(nil), secret...)}, nil
+}
+
+// Save sets a signed cookie holding the state.
+func (c *CookieStateStore) Save(w http.ResponseWriter, r *http.Request, state *AuthState) error {
+	if w == nil {
+		return errors.New("cookie state store requires a response writer")
+	}
+
+	if len(c.secret) < MinCookieStateSecretLength {
+		return errors.New("cookie state store requires a secret, use NewCookieStateStore")
+	}
+
+	payload, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+
+	http.SetCookie(w, c.cookie(state.Value, encoded+"."+c.sign(encoded), state.ExpiresAt))
+
+	return nil
+}
+
+// Consume verifies the cookie holding the state with the value, clears it, and returns the state.
+func (c *CookieStateStore) Consume(w http.ResponseWriter, r *http.Request, value string) (*AuthState, error) {
+	if value == "" || len(c.secret) < MinCookieStateSecretLength {
+		return nil, ErrInvalidState
+	}
+
+	cookie, err := r.Cookie(c.cookieName(value))
+	if err != nil {
+		return nil, ErrInvalidState
+	}
+
+	if w != nil {
+		cleared := c.cookie(value, "", time.Unix(0, 0))
+		cleared.MaxAge = -1
+		http.SetCookie(w, cleared)
+	}
+
+	parts := strings.SplitN(cookie.Value, ".", 2)
+	if len(parts) != 2 || !hmac.Equal([]byte(parts[1]), []byte(c.sign(parts[0]))) {
+		return nil, ErrInvalidState
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrInvalidState
+	}
+
+	state := &AuthState{}
+	if err := json.Unmarshal(payload, state); err != nil {
+		return nil, ErrInvalidState
+	}
+
+	if state.Value != value || !time.Now().Before(state.ExpiresAt) {
+		return nil, ErrInvalidState
+	}
+
+	return state, nil
+}
+
+func (c *CookieStateStore) cookie(stateValue, value string, expires time.Time) *http.Cookie {
+	path := c.Path
+	if path == "" {
+		path = "/"
+	}
+
+	return &http.Cookie{
+		Name:     c.cookieName(stateValue),
+		Value:    value,
+		Path:     path,
+		Expires:  expires,
+		Secure:   !c.Insecure,
+		HttpOnly: true,
+		// Lax, so the cookie is sent with the top-level redirect back from eBay.
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// cookieName returns the name of the cookie of a state. Each state has its own cookie, so
+// authorization attempts started in several tabs do not overwrite each other.
+func (c *CookieStateStore) cookieName(stateValue string) string {
+	name := c.Name
+	if name == "" {
+		name = DefaultStateCookieName
+	}
+
+	sum := sha256.Sum256([]byte(stateValue))
+
+	return name + "_" + base64.RawURLEncoding.EncodeToString(sum[:8])
+}
+
+func (c *CookieStateStore) sign(encoded string) string {
+	mac := hmac.New(sha256.New, c.secret)
+	mac.Write([]byte(encoded))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,213 @@
+// +build unit
+
+package oauth2_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	oauth2 "github.com/ralucas/go-ebay-oauth2"
+	"github.com/ralucas/go-ebay-oauth2/double"
+)
+
+func testStateStore(t *testing.T, store oauth2.StateStore) {
+	// redirect returns the request of the redirect back, carrying the cookies set by w.
+	redirect := func(w *httptest.ResponseRecorder, value string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/oauth?state="+url.QueryEscape(value), nil)
+		for _, c := range w.Result().Cookies() {
+			r.AddCookie(c)
+		}
+
+		return r
+	}
+
+	t.Run("ConsumesStateOnce", func(t *testing.T) {
+		state, err := oauth2.NewAuthState(time.Minute, map[string]string{"return_url": "/account"})
+		require.Nil(t, err)
+
+		w := httptest.NewRecorder()
+		require.Nil(t, store.Save(w, httptest.NewRequest(http.MethodGet, "/login", nil), state))
+
+		r := redirect(w, state.Value)
+
+		consumed, err := store.Consume(httptest.NewRecorder(), r, state.Value)
+		require.Nil(t, err, fmt.Sprintf("%v", err))
+
+		assert.Equal(t, state.Value, consumed.Value)
+		assert.Equal(t, "/account", consumed.Metadata["return_url"])
+
+		if _, ok := store.(*oauth2.MemoryStateStore); ok {
+			_, err = store.Consume(httptest.NewRecorder(), r, state.Value)
+			assert.True(t, errors.Is(err, oauth2.ErrInvalidState), fmt.Sprintf("%v", err))
+		}
+	})
+
+	t.Run("RejectsUnknownState", func(t *testing.T) {
+		state, err := oauth2.NewAuthState(time.Minute, nil)
+		require.Nil(t, err)
+
+		w := httptest.NewRecorder()
+		require.Nil(t, store.Save(w, httptest.NewRequest(http.MethodGet, "/login", nil), state))
+
+		_, err = store.Consume(httptest.NewRecorder(), redirect(w, "forged"), "forged")
+		assert.True(t, errors.Is(err, oauth2.ErrInvalidState), fmt.Sprintf("%v", err))
+	})
+
+	t.Run("RejectsExpiredState", func(t *testing.T) {
+		state, err := oauth2.NewAuthState(time.Minute, nil)
+		require.Nil(t, err)
+
+		state.ExpiresAt = time.Now().Add(-time.Second)
+
+		w := httptest.NewRecorder()
+		require.Nil(t, store.Save(w, httptest.NewRequest(http.MethodGet, "/login", nil), state))
+
+		_, err = store.Consume(httptest.NewRecorder(), redirect(w, state.Value), state.Value)
+		assert.True(t, errors.Is(err, oauth2.ErrInvalidState), fmt.Sprintf("%v", err))
+	})
+}
+
+func TestMemoryStateStore(t *testing.T) {
+	testStateStore(t, oauth2.NewMemoryStateStore())
+}
+
+func TestCookieStateStore(t *testing.T) {
+	store, err := oauth2.NewCookieStateStore([]byte("test-secret-test-secret-test-secret"))
+	require.Nil(t, err)
+
+	testStateStore(t, store)
+
+	t.Run("RejectsShortSecret", func(t *testing.T) {
+		for _, secret := range [][]byte{nil, []byte("short-secret")} {
+			_, err := oauth2.NewCookieStateStore(secret)
+			assert.NotNil(t, err)
+		}
+	})
+
+	t.Run("RejectsTamperedCookie", func(t *testing.T) {
+		state, err := oauth2.NewAuthState(time.Minute, map[string]string{"user_id": "1"})
+		require.Nil(t, err)
+
+		w := httptest.NewRecorder()
+		require.Nil(t, store.Save(w, httptest.NewRequest(http.MethodGet, "/login", nil), state))
+
+		cookie := w.Result().Cookies()[0]
+		assert.True(t, cookie.HttpOnly)
+		assert.True(t, cookie.Secure)
+
+		other, err := oauth2.NewCookieStateStore([]byte("other-secret-other-secret-other-s"))
+		require.Nil(t, err)
+
+		r := httptest.NewRequest(http.MethodGet, "/oauth", nil)
+		r.AddCookie(cookie)
+
+		_, err = other.Consume(httptest.NewRecorder(), r, state.Value)
+		assert.True(t, errors.Is(err, oauth2.ErrInvalidState), fmt.Sprintf("%v", err))
+	})
+
+	t.Run("ClearsCookie", func(t *testing.T) {
+		state, err := oauth2.NewAuthState(time.Minute, nil)
+		require.Nil(t, err)
+
+		w := httptest.NewRecorder()
+		require.Nil(t, store.Save(w, httptest.NewRequest(http.MethodGet, "/login", nil), state))
+
+		r := httptest.NewRequest(http.MethodGet, "/oauth", nil)
+		r.AddCookie(w.Result().Cookies()[0])
+
+		cw := httptest.NewRecorder()
+		_, err = store.Consume(cw, r, state.Value)
+		require.Nil(t, err)
+
+		cleared := cw.Result().Cookies()
+		require.Len(t, cleared, 1)
+		assert.True(t, cleared[0].MaxAge < 0)
+	})
+}
+
+func TestAuthorizationCode_BeginAndCompleteAuthorization(t *testing.T) {
+	client := oauth2.NewClient(testBaseUrl, testClientId, testClientSecret, testRedirectUri)
+	client.SetHTTPClient(&double.MockHTTPClient{})
+
+	ac := client.AuthorizationCode(testScopes)
+	store := oauth2.NewMemoryStateStore()
+
+	begin := func() string {
+		u, err := ac.BeginAuthorization(nil, nil, store, time.Minute, map[string]string{"user_id": "1"})
+		require.Nil(t, err, fmt.Sprintf("%v", err))
+
+		return u.Query().Get(oauth2.FieldState)
+	}
+
+	t.Run("GeneratesRandomStates", func(t *testing.T) {
+		first, second := begin(), begin()
+
+		assert.NotEmpty(t, first)
+		assert.NotEqual(t, first, second)
+	})
+
+	t.Run("CompletesOnce", func(t *testing.T) {
+		state := begin()
+
+		r := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/oauth?code=%s&state=%s", testCode, state), nil)
+
+		token, authState, err := ac.CompleteAuthorization(nil, r, store)
+		require.Nil(t, err, fmt.Sprintf("%v", err))
+
+		assert.NotNil(t, token)
+		assert.Equal(t, "1", authState.Metadata["user_id"])
+
+		_, _, err = ac.CompleteAuthorization(nil, r, store)
+		assert.True(t, errors.Is(err, oauth2.ErrInvalidState), fmt.Sprintf("%v", err))
+	})
+
+	t.Run("HandlersShareState", func(t *testing.T) {
+		authorize := &oauth2.AuthorizeHandler{
+			Flow:       ac,
+			StateStore: store,
+			Metadata: func(r *http.Request) map[string]string {
+				return map[string]string{"return_url": r.URL.Query().Get("return_url")}
+			},
+		}
+
+		var returnURL string
+
+		callback := &oauth2.CallbackHandler{
+			Flow:       ac,
+			StateStore: store,
+			OnSuccess: func(w http.ResponseWriter, r *http.Request, key string, token *oauth2.AccessToken) {
+				state, ok := oauth2.StateFromContext(r.Context())
+				require.True(t, ok)
+
+				returnURL = state.Metadata["return_url"]
+			},
+		}
+
+		rec := httptest.NewRecorder()
+		authorize.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/login?return_url=/orders", nil))
+
+		location, err := url.Parse(rec.Header().Get("Location"))
+		require.Nil(t, err)
+
+		state := location.Query().Get(oauth2.FieldState)
+
+		rec = httptest.NewRecorder()
+		callback.ServeHTTP(rec, httptest.NewRequest(
+			http.MethodGet,
+			fmt.Sprintf("/oauth?code=%s&state=%s", testCode, state),
+			nil,
+		).WithContext(context.Background()))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "/orders", returnURL)
+	})
+}
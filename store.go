@@ -0,0 +1,285 @@
+package oauth2
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrTokenNotFound is returned by a Store when it has no token for a key.
+var ErrTokenNotFound = errors.New("token not found")
+
+// ErrListingUnsupported is returned when the keys of a store cannot be listed.
+var ErrListingUnsupported = errors.New("store does not support listing keys")
+
+// Store persists tokens keyed by an account identifier, e.g. the eBay user ID of a seller, or a
+// name for an application token.
+type Store interface {
+	// Get returns the token stored for key, or ErrTokenNotFound.
+	Get(ctx context.Context, key string) (*AccessToken, error)
+	// Put stores the token for key, replacing any token stored before.
+	Put(ctx context.Context, key string, token *AccessToken) error
+	// Delete removes the token stored for key. Deleting a missing token is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// RecordStore stores serialized tokens as opaque records. It is implemented by MemoryStore and
+// FileStore, and lets decorators such as EncryptedStore control the serialization.
+type RecordStore interface {
+	// GetRecord returns the record stored for key, or ErrTokenNotFound.
+	GetRecord(ctx context.Context, key string) ([]byte, error)
+	// PutRecord stores the record for key, replacing any record stored before.
+	PutRecord(ctx context.Context, key string, record []byte) error
+	// DeleteRecord removes the record stored for key. Deleting a missing record is not an error.
+	DeleteRecord(ctx context.Context, key string) error
+}
+
+// KeyLister lists the keys of the tokens in a store. It is implemented by MemoryStore, FileStore,
+// and EncryptedStore when its RecordStore implements it.
+type KeyLister interface {
+	// Keys returns the keys of every stored token, sorted.
+	Keys(ctx context.Context) ([]string, error)
+}
+
+// MemoryStore is a Store that keeps tokens in memory. It is safe for concurrent use.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	records map[string][]byte
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: make(map[string][]byte)}
+}
+
+// Get returns the token stored for key, or ErrTokenNotFound.
+func (m *MemoryStore) Get(ctx context.Context, key string) (*AccessToken, error) {
+	return getToken(ctx, m, key)
+}
+
+// Put stores the token for key, replacing any token stored before.
+func (m *MemoryStore) Put(ctx context.Context, key string, token *AccessToken) error {
+	return putToken(ctx, m, key, token)
+}
+
+// Delete removes the token stored for key.
+func (m *MemoryStore) Delete(ctx context.Context, key string) error {
+	return m.DeleteRecord(ctx, key)
+}
+
+// GetRecord returns the record stored for key, or ErrTokenNotFound.
+func (m *MemoryStore) GetRecord(ctx context.Context, key string) ([]byte, error) {
+	m.mu.RLock()
+	record, ok := m.records[key]
+	m.mu.RUnlock()
+
+	if !ok {
+		return nil, ErrTokenNotFound
+	}
+
+	return append([]byte(nil), record...), nil
+}
+
+// PutRecord stores the record for key, replacing any record stored before.
+func (m *MemoryStore) PutRecord(ctx context.Context, key string, record []byte) error {
+	// Records are copied, so later changes by the caller do not reach the store.
+	record = append([]byte(nil), record...)
+
+	m.mu.Lock()
+	m.records[key] = record
+	m.mu.Unlock()
+
+	return nil
+}
+
+// DeleteRecord removes the record stored for key.
+func (m *MemoryStore) DeleteRecord(ctx context.Context, key string) error {
+	m.mu.Lock()
+	delete(m.records, key)
+	m.mu.Unlock()
+
+	return nil
+}
+
+// Keys returns the keys of every stored token, sorted.
+func (m *MemoryStore) Keys(ctx context.Context) ([]string, error) {
+	m.mu.RLock()
+	keys := make([]string, 0, len(m.records))
+	for key := range m.records {
+		keys = append(keys, key)
+	}
+	m.mu.RUnlock()
+
+	sort.Strings(keys)
+
+	return keys, nil
+}
+
+// FileStore is a Store that keeps each token in its own JSON file in a directory. Files are
+// written atomically and are only readable by their owner.
+type FileStore struct {
+	dir string
+}
+
+const (
+	fileStoreDirMode  = 0700
+	fileStoreFileMode = 0600
+	fileStoreExt      = ".json"
+)
+
+// NewFileStore returns a FileStore keeping tokens in dir, creating dir if it does not exist.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, fileStoreDirMode); err != nil {
+		return nil, fmt.Errorf("failed to create token store directory: %w", err)
+	}
+
+	return &FileStore{dir: dir}, nil
+}
+
+// Get returns the token stored for key, or ErrTokenNotFound.
+func (f *FileStore) Get(ctx context.Context, key string) (*AccessToken, error) {
+	return getToken(ctx, f, key)
+}
+
+// Put stores the token for key, replacing any token stored before.
+func (f *FileStore) Put(ctx context.Context, key string, token *AccessToken) error {
+	return putToken(ctx, f, key, token)
+}
+
+// Delete removes the token stored for key.
+func (f *FileStore) Delete(ctx context.Context, key string) error {
+	return f.DeleteRecord(ctx, key)
+}
+
+// GetRecord returns the record stored for key, or ErrTokenNotFound.
+func (f *FileStore) GetRecord(ctx context.Context, key string) ([]byte, error) {
+	record, err := os.ReadFile(f.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrTokenNotFound
+	}
+
+	return record, err
+}
+
+// PutRecord stores the record for key, replacing any record stored before.
+func (f *FileStore) PutRecord(ctx context.Context, key string, record []byte) error {
+	return f.write(key, record)
+}
+
+// DeleteRecord removes the record stored for key.
+func (f *FileStore) DeleteRecord(ctx context.Context, key string) error {
+	err := os.Remove(f.path(key))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+// Keys returns the keys of every stored token, sorted. Files that are not tokens, such as
+// temporary files, are skipped.
+func (f *FileStore) Keys(ctx context.Context) ([]string, error) {
+	entries, err := os.ReadDir(f.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		key, ok := keyFromFileName(entry.Name())
+		if !ok || entry.IsDir() {
+			continue
+		}
+
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys, nil
+}
+
+// write writes data to the file of key through a temporary file that is renamed into place,
+// so readers never see a partially written file.
+func (f *FileStore) write(key string, data []byte) (err error) {
+	tmp, err := os.CreateTemp(f.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if err = tmp.Chmod(fileStoreFileMode); err != nil {
+		return err
+	}
+
+	if _, err = tmp.Write(data); err != nil {
+		return err
+	}
+
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), f.path(key))
+}
+
+// path returns the file of key. Keys are base64 encoded, so any key makes a safe file name.
+func (f *FileStore) path(key string) string {
+	return filepath.Join(f.dir, base64.RawURLEncoding.EncodeToString([]byte(key))+fileStoreExt)
+}
+
+// keyFromFileName returns the key of a token file name, as made by FileStore.path.
+func keyFromFileName(name string) (string, bool) {
+	if strings.HasPrefix(name, ".") || !strings.HasSuffix(name, fileStoreExt) {
+		return "", false
+	}
+
+	key, err := base64.RawURLEncoding.DecodeString(strings.TrimSuffix(name, fileStoreExt))
+	if err != nil {
+		return "", false
+	}
+
+	return string(key), true
+}
+
+// getToken gets the record of key from records and decodes it as a JSON token.
+func getToken(ctx context.Context, records RecordStore, key string) (*AccessToken, error) {
+	record, err := records.GetRecord(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	token := &AccessToken{}
+	if err := json.Unmarshal(record, token); err != nil {
+		return nil, fmt.Errorf("failed to decode stored token: %w", err)
+	}
+
+	return token, nil
+}
+
+// putToken encodes the token as JSON and puts it in records under key.
+func putToken(ctx context.Context, records RecordStore, key string, token *AccessToken) error {
+	record, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+
+	return records.PutRecord(ctx, key, record)
+}
@@ -0,0 +1,195 @@
+// +build unit
+
+package oauth2_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	oauth2 "github.com/ralucas/go-ebay-oauth2"
+	"github.com/ralucas/go-ebay-oauth2/double"
+)
+
+func testStore(t *testing.T, store oauth2.Store) {
+	ctx := context.Background()
+
+	expiresAt := time.Date(2021, 11, 1, 14, 0, 0, 0, time.UTC)
+
+	token := &oauth2.AccessToken{
+		AccessToken:  "test-token",
+		ExpiresIn:    7200,
+		RefreshToken: testRefreshToken,
+		ExpiresAt:    expiresAt,
+	}
+
+	t.Run("ErrorsOnMissingToken", func(t *testing.T) {
+		_, err := store.Get(ctx, "missing")
+		assert.True(t, errors.Is(err, oauth2.ErrTokenNotFound), fmt.Sprintf("%v", err))
+	})
+
+	t.Run("GetsPutToken", func(t *testing.T) {
+		require.Nil(t, store.Put(ctx, "seller/1", token))
+
+		got, err := store.Get(ctx, "seller/1")
+		require.Nil(t, err)
+
+		assert.Equal(t, token, got)
+	})
+
+	t.Run("ReplacesToken", func(t *testing.T) {
+		replacement := *token
+		replacement.AccessToken = "replacement-token"
+
+		require.Nil(t, store.Put(ctx, "seller/1", &replacement))
+
+		got, err := store.Get(ctx, "seller/1")
+		require.Nil(t, err)
+
+		assert.Equal(t, "replacement-token", got.AccessToken)
+	})
+
+	t.Run("DeletesToken", func(t *testing.T) {
+		require.Nil(t, store.Delete(ctx, "seller/1"))
+		require.Nil(t, store.Delete(ctx, "seller/1"))
+
+		_, err := store.Get(ctx, "seller/1")
+		assert.True(t, errors.Is(err, oauth2.ErrTokenNotFound), fmt.Sprintf("%v", err))
+	})
+
+	t.Run("ListsKeys", func(t *testing.T) {
+		lister, ok := store.(oauth2.KeyLister)
+		require.True(t, ok)
+
+		require.Nil(t, store.Put(ctx, "seller/2", token))
+		require.Nil(t, store.Put(ctx, "seller/1", token))
+
+		keys, err := lister.Keys(ctx)
+		require.Nil(t, err, fmt.Sprintf("%v", err))
+		assert.Equal(t, []string{"seller/1", "seller/2"}, keys)
+
+		require.Nil(t, store.Delete(ctx, "seller/1"))
+		require.Nil(t, store.Delete(ctx, "seller/2"))
+
+		keys, err = lister.Keys(ctx)
+		require.Nil(t, err)
+		assert.Empty(t, keys)
+	})
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, oauth2.NewMemoryStore())
+}
+
+func TestFileStore(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "tokens")
+
+	store, err := oauth2.NewFileStore(dir)
+	require.Nil(t, err)
+
+	testStore(t, store)
+
+	t.Run("WritesOwnerOnlyFiles", func(t *testing.T) {
+		require.Nil(t, store.Put(context.Background(), "../escape", &oauth2.AccessToken{AccessToken: "test-token"}))
+
+		entries, err := os.ReadDir(dir)
+		require.Nil(t, err)
+		require.Len(t, entries, 1)
+
+		info, err := entries[0].Info()
+		require.Nil(t, err)
+
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	})
+
+	t.Run("ListsOnlyTokenFiles", func(t *testing.T) {
+		require.Nil(t, os.WriteFile(filepath.Join(dir, ".tmp-123"), []byte("{}"), 0600))
+		require.Nil(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("notes"), 0600))
+
+		keys, err := store.Keys(context.Background())
+		require.Nil(t, err, fmt.Sprintf("%v", err))
+
+		assert.Equal(t, []string{"../escape"}, keys)
+	})
+}
+
+func TestCachingTokenSource_Store(t *testing.T) {
+	client := oauth2.NewClient(testBaseUrl, testClientId, testClientSecret, testRedirectUri)
+	ctx := context.Background()
+
+	t.Run("PutsFetchedToken", func(t *testing.T) {
+		client.SetHTTPClient(&double.MockHTTPClient{})
+		store := oauth2.NewMemoryStore()
+
+		ts := oauth2.NewCachingTokenSource(
+			client.ClientCredentials(testScopes),
+			oauth2.WithStore(store, "app"),
+			oauth2.WithExpiryMargin(10*time.Second),
+		)
+
+		token, err := ts.Token()
+		require.Nil(t, err, fmt.Sprintf("%v", err))
+
+		stored, err := store.Get(ctx, "app")
+		require.Nil(t, err)
+
+		assert.Equal(t, token.AccessToken, stored.AccessToken)
+		assert.True(t, token.ExpiresAt.Equal(stored.ExpiresAt))
+	})
+
+	t.Run("UsesStoredToken", func(t *testing.T) {
+		spyHttpClient := &double.SpyHTTPClient{}
+		spyHttpClient.Reset()
+
+		client.SetHTTPClient(spyHttpClient)
+		store := oauth2.NewMemoryStore()
+
+		require.Nil(t, store.Put(ctx, "app", &oauth2.AccessToken{
+			AccessToken: "stored-token",
+			ExpiresAt:   time.Now().Add(time.Hour),
+		}))
+
+		ts := oauth2.NewCachingTokenSource(client.ClientCredentials(testScopes), oauth2.WithStore(store, "app"))
+
+		token, err := ts.Token()
+		require.Nil(t, err, fmt.Sprintf("%v", err))
+
+		assert.Equal(t, "stored-token", token.AccessToken)
+		assert.Equal(t, 0, spyHttpClient.CallCount("Do"))
+	})
+
+	t.Run("RefreshesStoredUserToken", func(t *testing.T) {
+		spyHttpClient := &double.SpyHTTPClient{}
+		spyHttpClient.Reset()
+
+		client.SetHTTPClient(spyHttpClient)
+		store := oauth2.NewMemoryStore()
+
+		require.Nil(t, store.Put(ctx, "seller", &oauth2.AccessToken{
+			AccessToken:  "expired-token",
+			ExpiresAt:    time.Now().Add(-time.Second),
+			RefreshToken: testRefreshToken,
+		}))
+
+		ts := oauth2.NewStoredTokenSource(client, store, "seller", nil, oauth2.WithExpiryMargin(10*time.Second))
+
+		token, err := ts.Token()
+		require.Nil(t, err, fmt.Sprintf("%v", err))
+
+		assert.Equal(t, "test-token", token.AccessToken)
+		assert.Equal(t, 1, spyHttpClient.CallCount("Do"))
+
+		stored, err := store.Get(ctx, "seller")
+		require.Nil(t, err)
+
+		assert.Equal(t, "test-token", stored.AccessToken)
+		assert.Equal(t, testRefreshToken, stored.RefreshToken)
+	})
+}
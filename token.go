@@ -0,0 +1,188 @@
+package oauth2
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// AccessToken is the response from each OAuth2 flow.
+//
+// ExpiresIn and RefreshTokenExpiresIn are relative to the moment the token was issued, so the
+// absolute IssuedAt, ExpiresAt and RefreshTokenExpiresAt times are filled in when the token
+// is received. They are kept when the token is encoded to JSON, so a token that was stored and
+// loaded again is still evaluated correctly.
+//
+// Scopes are the scopes of the access token, as full URLs. eBay does not return them, so they are
+// the scopes that were requested, and are kept along with the token. RefreshTokenScopes are the
+// scopes the user consented to, granted to the refresh token by the authorization code flow. A
+// refresh may ask for a subset of them, which is then the Scopes of the new access token; token
+// sources and the Refresher carry RefreshTokenScopes over along with the refresh token.
+type AccessToken struct {
+	AccessToken           string    `json:"access_token"`
+	ExpiresIn             int       `json:"expires_in"`
+	RefreshToken          string    `json:"refresh_token"`
+	RefreshTokenExpiresIn int       `json:"refresh_token_expires_in"`
+	TokenType             string    `json:"token_type"`
+	IssuedAt              time.Time `json:"issued_at"`
+	ExpiresAt             time.Time `json:"expires_at"`
+	RefreshTokenExpiresAt time.Time `json:"refresh_token_expires_at"`
+	Scopes                []string  `json:"scopes,omitempty"`
+	RefreshTokenScopes    []string  `json:"refresh_token_scopes,omitempty"`
+}
+
+// accessToken has the fields of AccessToken without its methods, so it can be used
+// to encode and decode the token without recursing into MarshalJSON and UnmarshalJSON.
+type accessToken AccessToken
+
+// accessTokenJSON is the JSON form of AccessToken. The absolute times are pointers
+// so unset times are omitted, rather than encoded as the zero time. Scope is the space-separated
+// "scope" of a token response, which is only decoded.
+type accessTokenJSON struct {
+	accessToken
+	Scope                 string     `json:"scope,omitempty"`
+	IssuedAt              *time.Time `json:"issued_at,omitempty"`
+	ExpiresAt             *time.Time `json:"expires_at,omitempty"`
+	RefreshTokenExpiresAt *time.Time `json:"refresh_token_expires_at,omitempty"`
+}
+
+// MarshalJSON encodes the token, including the absolute times that are set.
+func (t AccessToken) MarshalJSON() ([]byte, error) {
+	return json.Marshal(accessTokenJSON{
+		accessToken:           accessToken(t),
+		IssuedAt:              timeOrNil(t.IssuedAt),
+		ExpiresAt:             timeOrNil(t.ExpiresAt),
+		RefreshTokenExpiresAt: timeOrNil(t.RefreshTokenExpiresAt),
+	})
+}
+
+// UnmarshalJSON decodes either an eBay token response or a token encoded with MarshalJSON.
+func (t *AccessToken) UnmarshalJSON(data []byte) error {
+	var tj accessTokenJSON
+	if err := json.Unmarshal(data, &tj); err != nil {
+		return err
+	}
+
+	*t = AccessToken(tj.accessToken)
+
+	if tj.IssuedAt != nil {
+		t.IssuedAt = *tj.IssuedAt
+	}
+
+	if tj.ExpiresAt != nil {
+		t.ExpiresAt = *tj.ExpiresAt
+	}
+
+	if tj.RefreshTokenExpiresAt != nil {
+		t.RefreshTokenExpiresAt = *tj.RefreshTokenExpiresAt
+	}
+
+	if len(t.Scopes) == 0 && tj.Scope != "" {
+		t.Scopes = strings.Fields(tj.Scope)
+	}
+
+	return nil
+}
+
+// Expired reports whether the access token has expired. A token without an access
+// token or an expiry time is considered expired.
+func (t *AccessToken) Expired() bool {
+	return t.ExpiresWithin(0)
+}
+
+// ExpiresWithin reports whether the access token expires within margin from now.
+func (t *AccessToken) ExpiresWithin(margin time.Duration) bool {
+	return t.expiresWithin(time.Now(), margin)
+}
+
+// RefreshTokenExpired reports whether the refresh token has expired. A token without
+// a refresh token or a refresh token expiry time is considered expired.
+func (t *AccessToken) RefreshTokenExpired() bool {
+	return t.RefreshTokenExpiresWithin(0)
+}
+
+// RefreshTokenExpiresWithin reports whether the refresh token expires within margin from now.
+func (t *AccessToken) RefreshTokenExpiresWithin(margin time.Duration) bool {
+	return t.refreshTokenExpiresWithin(time.Now(), margin)
+}
+
+func (t *AccessToken) expiresWithin(now time.Time, margin time.Duration) bool {
+	if t.AccessToken == "" || t.ExpiresAt.IsZero() {
+		return true
+	}
+
+	return !now.Add(margin).Before(t.ExpiresAt)
+}
+
+func (t *AccessToken) refreshTokenExpiresWithin(now time.Time, margin time.Duration) bool {
+	if t.RefreshToken == "" || t.RefreshTokenExpiresAt.IsZero() {
+		return true
+	}
+
+	return !now.Add(margin).Before(t.RefreshTokenExpiresAt)
+}
+
+// setExpiry sets the absolute times of a token issued at now from its relative expiries.
+func (t *AccessToken) setExpiry(now time.Time) {
+	t.IssuedAt = now
+	t.ExpiresAt = now.Add(time.Duration(t.ExpiresIn) * time.Second)
+
+	if t.RefreshToken != "" && t.RefreshTokenExpiresIn > 0 {
+		t.RefreshTokenExpiresAt = now.Add(time.Duration(t.RefreshTokenExpiresIn) * time.Second)
+	}
+}
+
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+
+	return &t
+}
+
+// HasScopes reports whether the access token was granted every one of the scopes, given by their
+// full URLs or short names. Unknown short names are never granted.
+func (t *AccessToken) HasScopes(scopes ...string) bool {
+	missing, err := t.MissingScopes(scopes)
+
+	return err == nil && len(missing) == 0
+}
+
+// MissingScopes returns the scopes, as full URLs, that the access token was not granted. It
+// returns ErrUnknownScope for unknown short names.
+func (t *AccessToken) MissingScopes(scopes []string) ([]string, error) {
+	return missingScopes(t.Scopes, scopes)
+}
+
+// GrantedScopes returns the scopes the user consented to: the RefreshTokenScopes, or the Scopes
+// of a token without them, such as an application token or a token stored before they were
+// recorded.
+func (t *AccessToken) GrantedScopes() []string {
+	if len(t.RefreshTokenScopes) > 0 {
+		return t.RefreshTokenScopes
+	}
+
+	return t.Scopes
+}
+
+// missingScopes returns the scopes, as full URLs, that are not among the granted ones.
+func missingScopes(grantedScopes, scopes []string) ([]string, error) {
+	required, err := ExpandScopes(scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	granted := make(map[string]bool, len(grantedScopes))
+	for _, scope := range grantedScopes {
+		granted[scope] = true
+	}
+
+	var missing []string
+	for _, scope := range required {
+		if !granted[scope] {
+			missing = append(missing, scope)
+		}
+	}
+
+	return missing, nil
+}
@@ -0,0 +1,289 @@
+package oauth2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultExpiryMargin is how long before its expiry a cached token is replaced by default.
+const DefaultExpiryMargin = time.Minute
+
+// TokenSource supplies access tokens.
+type TokenSource interface {
+	Token() (*AccessToken, error)
+	TokenWithContext(context.Context) (*AccessToken, error)
+}
+
+// AccessTokenFetcher fetches a new access token on every call. It is satisfied by the
+// client credentials and refresh token flows.
+type AccessTokenFetcher interface {
+	AccessTokenWithContext(context.Context) (*AccessToken, error)
+}
+
+// CachingTokenSource is a TokenSource that reuses its token until it is near expiry.
+type CachingTokenSource interface {
+	TokenSource
+	// Invalidate drops the cached token, so the next call fetches a new one.
+	Invalidate()
+}
+
+type cachingTokenSource struct {
+	fetcher  AccessTokenFetcher
+	clock    Clock
+	margin   time.Duration
+	store    Store
+	storeKey string
+
+	mu     sync.Mutex
+	token  *AccessToken
+	loaded bool
+}
+
+// TokenSourceOption configures a CachingTokenSource.
+type TokenSourceOption func(*cachingTokenSource)
+
+// WithExpiryMargin sets how long before its expiry the cached token is replaced.
+func WithExpiryMargin(margin time.Duration) TokenSourceOption {
+	return func(c *cachingTokenSource) {
+		c.margin = margin
+	}
+}
+
+// WithInitialToken seeds the cache with a copy of token, e.g. one returned by the authorization
+// code flow.
+func WithInitialToken(token *AccessToken) TokenSourceOption {
+	return func(c *cachingTokenSource) {
+		if token == nil {
+			c.token = nil
+			return
+		}
+
+		c.token = copyAccessToken(token)
+	}
+}
+
+// WithStore persists the tokens of the source in store under key. A token found in the store
+// is used before fetching a new one, and every fetched token is put in the store.
+func WithStore(store Store, key string) TokenSourceOption {
+	return func(c *cachingTokenSource) {
+		c.store = store
+		c.storeKey = key
+	}
+}
+
+// NewCachingTokenSource returns a CachingTokenSource that fetches tokens with fetcher and
+// returns the cached token until it expires within the expiry margin, as told by the Clock of
+// the client of fetcher when it is a flow.
+//
+// When a fetched token has no refresh token, as is the case for tokens returned by the refresh
+// token flow, the refresh token of the previously cached token is carried over. So are its scopes,
+// when the fetched token has none.
+func NewCachingTokenSource(fetcher AccessTokenFetcher, options ...TokenSourceOption) CachingTokenSource {
+	c := &cachingTokenSource{
+		fetcher: fetcher,
+		clock:   clockOf(fetcher),
+		margin:  DefaultExpiryMargin,
+	}
+
+	for _, opt := range options {
+		opt(c)
+	}
+
+	return c
+}
+
+// NewRefreshingTokenSource returns a CachingTokenSource for a user token, that uses the refresh
+// token of the cached token, starting with token, to get a new access token when the cached one
+// is near expiry. It fails when token is nil or has no refresh token. The scopes are optional, as
+// in Oauth2Client.RefreshToken.
+func NewRefreshingTokenSource(
+	client Oauth2Client,
+	token *AccessToken,
+	scopes []string,
+	options ...TokenSourceOption,
+) (CachingTokenSource, error) {
+	if token == nil {
+		return nil, errors.New("missing token")
+	}
+
+	if token.RefreshToken == "" {
+		return nil, errors.New("missing refresh token")
+	}
+
+	fetcher := &cachedRefreshFetcher{client: client, scopes: scopes}
+	options = append([]TokenSourceOption{WithInitialToken(token)}, options...)
+
+	c := NewCachingTokenSource(fetcher, options...).(*cachingTokenSource)
+	fetcher.source = c
+
+	return c, nil
+}
+
+// NewStoredTokenSource returns a CachingTokenSource for the user token stored in store under key.
+// The stored refresh token is used to get a new access token when the stored one is near expiry,
+// and the new token is put back in the store. The scopes are optional, as in
+// Oauth2Client.RefreshToken.
+func NewStoredTokenSource(
+	client Oauth2Client,
+	store Store,
+	key string,
+	scopes []string,
+	options ...TokenSourceOption,
+) CachingTokenSource {
+	fetcher := &storedRefreshFetcher{client: client, store: store, key: key, scopes: scopes}
+	options = append([]TokenSourceOption{WithStore(store, key)}, options...)
+
+	return NewCachingTokenSource(fetcher, options...)
+}
+
+// cachedRefreshFetcher refreshes the token cached by source with its refresh token, so a refresh
+// token rotated by eBay is used from then on. It is only called by source, with its mu held.
+type cachedRefreshFetcher struct {
+	client Oauth2Client
+	source *cachingTokenSource
+	scopes []string
+}
+
+func (f *cachedRefreshFetcher) Clock() Clock {
+	return clockOf(f.client)
+}
+
+func (f *cachedRefreshFetcher) AccessTokenWithContext(ctx context.Context) (*AccessToken, error) {
+	if f.source.token == nil || f.source.token.RefreshToken == "" {
+		return nil, errors.New("missing refresh token")
+	}
+
+	return f.client.RefreshToken(f.source.token.RefreshToken, f.scopes).AccessTokenWithContext(ctx)
+}
+
+// storedRefreshFetcher refreshes the token stored under key with its refresh token.
+type storedRefreshFetcher struct {
+	client Oauth2Client
+	store  Store
+	key    string
+	scopes []string
+}
+
+func (s *storedRefreshFetcher) Clock() Clock {
+	return clockOf(s.client)
+}
+
+func (s *storedRefreshFetcher) AccessTokenWithContext(ctx context.Context) (*AccessToken, error) {
+	stored, err := s.store.Get(ctx, s.key)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.client.RefreshToken(stored.RefreshToken, s.scopes).AccessTokenWithContext(ctx)
+}
+
+// Token returns the cached token, fetching a new one if it is near expiry.
+func (c *cachingTokenSource) Token() (*AccessToken, error) {
+	return c.TokenWithContext(context.Background())
+}
+
+// TokenWithContext returns the cached token, fetching a new one with ctx if it is near expiry.
+func (c *cachingTokenSource) TokenWithContext(ctx context.Context) (*AccessToken, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.load(ctx); err != nil {
+		return nil, err
+	}
+
+	if c.token != nil && !c.token.expiresWithin(c.clock.Now(), c.margin) {
+		return c.copyToken(), nil
+	}
+
+	token, err := c.fetcher.AccessTokenWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	carryOver(token, c.token)
+
+	c.token = token
+
+	if c.store != nil {
+		if err := c.store.Put(ctx, c.storeKey, token); err != nil {
+			return nil, fmt.Errorf("failed to store token: %w", err)
+		}
+	}
+
+	return c.copyToken(), nil
+}
+
+// load loads the token from the store the first time it is needed. Must be called with mu held.
+func (c *cachingTokenSource) load(ctx context.Context) error {
+	if c.store == nil || c.loaded || c.token != nil {
+		return nil
+	}
+
+	token, err := c.store.Get(ctx, c.storeKey)
+	if err != nil && !errors.Is(err, ErrTokenNotFound) {
+		return fmt.Errorf("failed to load token: %w", err)
+	}
+
+	c.token = token
+	c.loaded = true
+
+	return nil
+}
+
+// Invalidate drops the cached access token, keeping its refresh token, so the next call
+// fetches a new one.
+func (c *cachingTokenSource) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token == nil {
+		return
+	}
+
+	invalidated := *c.token
+	invalidated.AccessToken = ""
+	invalidated.ExpiresAt = time.Time{}
+
+	c.token = &invalidated
+}
+
+// carryOver completes a fetched token with the previous token of the same account, if any. When
+// token has no refresh token, as is the case for tokens returned by the refresh token flow, the
+// refresh token of previous is carried over, along with the scopes granted to it. A token
+// without scopes, refreshed without asking for any, has every scope granted to previous.
+func carryOver(token, previous *AccessToken) {
+	if previous == nil {
+		return
+	}
+
+	if token.RefreshToken == "" {
+		token.RefreshToken = previous.RefreshToken
+		token.RefreshTokenExpiresIn = previous.RefreshTokenExpiresIn
+		token.RefreshTokenExpiresAt = previous.RefreshTokenExpiresAt
+	}
+
+	if token.RefreshToken != "" && len(token.RefreshTokenScopes) == 0 && previous.RefreshToken != "" {
+		token.RefreshTokenScopes = append([]string(nil), previous.GrantedScopes()...)
+	}
+
+	if len(token.Scopes) == 0 {
+		token.Scopes = append([]string(nil), previous.GrantedScopes()...)
+	}
+}
+
+// copyToken returns a copy of the cached token, so callers cannot modify the cache.
+func (c *cachingTokenSource) copyToken() *AccessToken {
+	return copyAccessToken(c.token)
+}
+
+// copyAccessToken returns a copy of token that shares none of its memory.
+func copyAccessToken(token *AccessToken) *AccessToken {
+	copied := *token
+	copied.Scopes = append([]string(nil), token.Scopes...)
+	copied.RefreshTokenScopes = append([]string(nil), token.RefreshTokenScopes...)
+
+	return &copied
+}
@@ -0,0 +1,204 @@
+// +build unit
+
+package oauth2_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	oauth2 "github.com/ralucas/go-ebay-oauth2"
+	"github.com/ralucas/go-ebay-oauth2/double"
+)
+
+func TestCachingTokenSource_Token(t *testing.T) {
+	client := oauth2.NewClient(testBaseUrl, testClientId, testClientSecret, testRedirectUri)
+
+	t.Run("ReusesTokenUntilNearExpiry", func(t *testing.T) {
+		spyHttpClient := &double.SpyHTTPClient{}
+		spyHttpClient.Reset()
+
+		client.SetHTTPClient(spyHttpClient)
+
+		ts := oauth2.NewCachingTokenSource(
+			client.ClientCredentials(testScopes),
+			oauth2.WithExpiryMargin(10*time.Second),
+		)
+
+		first, err := ts.Token()
+		require.Nil(t, err, fmt.Sprintf("%v", err))
+
+		second, err := ts.Token()
+		require.Nil(t, err, fmt.Sprintf("%v", err))
+
+		assert.Equal(t, first, second)
+		assert.Equal(t, 1, spyHttpClient.CallCount("Do"))
+	})
+
+	t.Run("FetchesTokenWhenNearExpiry", func(t *testing.T) {
+		spyHttpClient := &double.SpyHTTPClient{}
+		spyHttpClient.Reset()
+
+		client.SetHTTPClient(spyHttpClient)
+
+		// The spy issues tokens expiring in 60 seconds, always within the margin.
+		ts := oauth2.NewCachingTokenSource(
+			client.ClientCredentials(testScopes),
+			oauth2.WithExpiryMargin(2*time.Minute),
+		)
+
+		_, err := ts.Token()
+		require.Nil(t, err, fmt.Sprintf("%v", err))
+
+		_, err = ts.Token()
+		require.Nil(t, err, fmt.Sprintf("%v", err))
+
+		assert.Equal(t, 2, spyHttpClient.CallCount("Do"))
+	})
+
+	t.Run("FetchesTokenAfterInvalidate", func(t *testing.T) {
+		spyHttpClient := &double.SpyHTTPClient{}
+		spyHttpClient.Reset()
+
+		client.SetHTTPClient(spyHttpClient)
+
+		ts := oauth2.NewCachingTokenSource(
+			client.ClientCredentials(testScopes),
+			oauth2.WithExpiryMargin(10*time.Second),
+		)
+
+		_, err := ts.Token()
+		require.Nil(t, err, fmt.Sprintf("%v", err))
+
+		ts.Invalidate()
+
+		_, err = ts.Token()
+		require.Nil(t, err, fmt.Sprintf("%v", err))
+
+		assert.Equal(t, 2, spyHttpClient.CallCount("Do"))
+	})
+
+	t.Run("RefreshesUserTokenKeepingRefreshToken", func(t *testing.T) {
+		spyHttpClient := &double.SpyHTTPClient{}
+		spyHttpClient.Reset()
+
+		client.SetHTTPClient(spyHttpClient)
+
+		refreshTokenExpiresAt := time.Now().Add(time.Hour)
+
+		initial := &oauth2.AccessToken{
+			AccessToken:           "expired-token",
+			ExpiresAt:             time.Now().Add(-time.Second),
+			RefreshToken:          testRefreshToken,
+			RefreshTokenExpiresAt: refreshTokenExpiresAt,
+		}
+
+		ts, err := oauth2.NewRefreshingTokenSource(
+			client,
+			initial,
+			testScopes,
+			oauth2.WithExpiryMargin(10*time.Second),
+		)
+		require.Nil(t, err)
+
+		token, err := ts.Token()
+		require.Nil(t, err, fmt.Sprintf("%v", err))
+
+		assert.Equal(t, "test-token", token.AccessToken)
+		assert.Equal(t, testRefreshToken, token.RefreshToken)
+		assert.Equal(t, refreshTokenExpiresAt, token.RefreshTokenExpiresAt)
+		assert.Equal(t, 1, spyHttpClient.CallCount("Do"))
+	})
+
+	t.Run("UsesValidInitialToken", func(t *testing.T) {
+		spyHttpClient := &double.SpyHTTPClient{}
+		spyHttpClient.Reset()
+
+		client.SetHTTPClient(spyHttpClient)
+
+		initial := &oauth2.AccessToken{
+			AccessToken:  "valid-token",
+			ExpiresAt:    time.Now().Add(time.Hour),
+			RefreshToken: testRefreshToken,
+		}
+
+		ts, err := oauth2.NewRefreshingTokenSource(client, initial, nil)
+		require.Nil(t, err)
+
+		// Changes to the initial token do not reach the cache.
+		initial.AccessToken = "modified-token"
+
+		token, err := ts.Token()
+		require.Nil(t, err, fmt.Sprintf("%v", err))
+
+		assert.Equal(t, "valid-token", token.AccessToken)
+		assert.Equal(t, 0, spyHttpClient.CallCount("Do"))
+	})
+
+	t.Run("UsesClockOfClient", func(t *testing.T) {
+		spyHttpClient := &double.SpyHTTPClient{}
+		spyHttpClient.Reset()
+
+		clocked, err := oauth2.New(
+			testClientId,
+			testClientSecret,
+			testRuName,
+			oauth2.WithHTTPClient(spyHttpClient),
+			oauth2.WithClock(fixedClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}),
+		)
+		require.Nil(t, err)
+
+		ts := oauth2.NewCachingTokenSource(clocked.ClientCredentials(testScopes), oauth2.WithExpiryMargin(10*time.Second))
+
+		for i := 0; i < 3; i++ {
+			_, err := ts.Token()
+			require.Nil(t, err, fmt.Sprintf("%v", err))
+		}
+
+		assert.Equal(t, 1, spyHttpClient.CallCount("Do"))
+	})
+
+	t.Run("RejectsTokenWithoutRefreshToken", func(t *testing.T) {
+		_, err := oauth2.NewRefreshingTokenSource(client, nil, nil)
+		assert.NotNil(t, err)
+
+		_, err = oauth2.NewRefreshingTokenSource(client, &oauth2.AccessToken{AccessToken: "test-token"}, nil)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("UsesRotatedRefreshToken", func(t *testing.T) {
+		httpClient := &double.SequenceHTTPClient{Responses: []double.StubResponse{
+			{StatusCode: http.StatusOK, Body: `{"access_token":"first-token","expires_in":1,"refresh_token":"rotated-refresh-token"}`},
+			{StatusCode: http.StatusOK, Body: `{"access_token":"second-token","expires_in":7200}`},
+		}}
+		httpClient.Reset()
+
+		rotatingClient := oauth2.NewClient(testBaseUrl, testClientId, testClientSecret, testRedirectUri)
+		rotatingClient.SetHTTPClient(httpClient)
+
+		initial := &oauth2.AccessToken{AccessToken: "expired-token", RefreshToken: testRefreshToken}
+
+		ts, err := oauth2.NewRefreshingTokenSource(rotatingClient, initial, nil, oauth2.WithExpiryMargin(10*time.Second))
+		require.Nil(t, err)
+
+		_, err = ts.Token()
+		require.Nil(t, err, fmt.Sprintf("%v", err))
+
+		token, err := ts.Token()
+		require.Nil(t, err, fmt.Sprintf("%v", err))
+
+		assert.Equal(t, "second-token", token.AccessToken)
+		assert.Equal(t, "rotated-refresh-token", token.RefreshToken)
+		require.Equal(t, 2, httpClient.CallCount("Do"))
+
+		body, err := io.ReadAll(httpClient.Calls("Do")[1].Arguments()[0].(*http.Request).Body)
+		require.Nil(t, err)
+
+		assert.Contains(t, string(body), "refresh_token=rotated-refresh-token")
+	})
+}
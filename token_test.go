@@ -0,0 +1,152 @@
+// +build unit
+
+package oauth2_test
+
+import (
+	"errors"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	oauth2 "github.com/ralucas/go-ebay-oauth2"
+	"github.com/ralucas/go-ebay-oauth2/double"
+)
+
+func TestAccessToken_Expiry(t *testing.T) {
+	t.Run("SetsAbsoluteTimesOnReceive", func(t *testing.T) {
+		client := oauth2.NewClient(testBaseUrl, testClientId, testClientSecret, testRedirectUri)
+		client.SetHTTPClient(&double.MockHTTPClient{})
+
+		before := time.Now()
+
+		token, err := client.ClientCredentials(testScopes).AccessToken()
+		require.Nil(t, err, fmt.Sprintf("%v", err))
+
+		assert.False(t, token.IssuedAt.Before(before))
+		assert.Equal(t, token.IssuedAt.Add(time.Duration(token.ExpiresIn)*time.Second), token.ExpiresAt)
+		assert.True(t, token.RefreshTokenExpiresAt.IsZero())
+
+		assert.False(t, token.Expired())
+		assert.True(t, token.ExpiresWithin(time.Duration(token.ExpiresIn)*time.Second))
+		assert.True(t, token.RefreshTokenExpired())
+	})
+
+	t.Run("ReportsExpiry", func(t *testing.T) {
+		now := time.Now()
+
+		token := oauth2.AccessToken{
+			AccessToken:           "test-token",
+			RefreshToken:          "test-refresh-token",
+			ExpiresAt:             now.Add(time.Minute),
+			RefreshTokenExpiresAt: now.Add(time.Hour),
+		}
+
+		assert.False(t, token.Expired())
+		assert.False(t, token.ExpiresWithin(30*time.Second))
+		assert.True(t, token.ExpiresWithin(2*time.Minute))
+
+		assert.False(t, token.RefreshTokenExpired())
+		assert.False(t, token.RefreshTokenExpiresWithin(30*time.Minute))
+		assert.True(t, token.RefreshTokenExpiresWithin(2*time.Hour))
+
+		token.ExpiresAt = now.Add(-time.Second)
+		assert.True(t, token.Expired())
+	})
+
+	t.Run("TreatsUnknownExpiryAsExpired", func(t *testing.T) {
+		token := oauth2.AccessToken{AccessToken: "test-token", ExpiresIn: 7200}
+
+		assert.True(t, token.Expired())
+		assert.True(t, token.RefreshTokenExpired())
+	})
+}
+
+func TestAccessToken_JSON(t *testing.T) {
+	t.Run("RoundTripsAbsoluteTimes", func(t *testing.T) {
+		issuedAt := time.Date(2021, 11, 1, 12, 0, 0, 0, time.UTC)
+
+		token := oauth2.AccessToken{
+			AccessToken:           "test-token",
+			ExpiresIn:             7200,
+			RefreshToken:          "test-refresh-token",
+			RefreshTokenExpiresIn: 47304000,
+			TokenType:             "User Access Token",
+			IssuedAt:              issuedAt,
+			ExpiresAt:             issuedAt.Add(7200 * time.Second),
+			RefreshTokenExpiresAt: issuedAt.Add(47304000 * time.Second),
+			Scopes:                testScopes,
+		}
+
+		b, err := json.Marshal(token)
+		require.Nil(t, err)
+
+		assert.Contains(t, string(b), `"expires_at":"2021-11-01T14:00:00Z"`)
+
+		decoded := oauth2.AccessToken{}
+		require.Nil(t, json.Unmarshal(b, &decoded))
+
+		assert.Equal(t, token, decoded)
+	})
+
+	t.Run("OmitsUnsetTimes", func(t *testing.T) {
+		b, err := json.Marshal(oauth2.AccessToken{AccessToken: "test-token"})
+		require.Nil(t, err)
+
+		assert.NotContains(t, string(b), "issued_at")
+		assert.NotContains(t, string(b), "expires_at")
+	})
+
+	t.Run("DecodesTokenResponse", func(t *testing.T) {
+		body := `{"access_token":"test-token","expires_in":7200,"refresh_token":"test-refresh-token","refresh_token_expires_in":47304000,"token_type":"User Access Token"}`
+
+		decoded := oauth2.AccessToken{}
+		require.Nil(t, json.Unmarshal([]byte(body), &decoded))
+
+		assert.Equal(t, "test-token", decoded.AccessToken)
+		assert.Equal(t, 7200, decoded.ExpiresIn)
+		assert.Equal(t, 47304000, decoded.RefreshTokenExpiresIn)
+		assert.True(t, decoded.ExpiresAt.IsZero())
+		assert.Empty(t, decoded.Scopes)
+	})
+
+	t.Run("DecodesResponseScope", func(t *testing.T) {
+		body := `{"access_token":"test-token","scope":"https://api.ebay.com/oauth/api_scope https://api.ebay.com/oauth/api_scope/buy.item.feed"}`
+
+		decoded := oauth2.AccessToken{}
+		require.Nil(t, json.Unmarshal([]byte(body), &decoded))
+
+		assert.Equal(t, testScopes, decoded.Scopes)
+	})
+}
+
+func TestAccessToken_Scopes(t *testing.T) {
+	token := &oauth2.AccessToken{
+		AccessToken: "test-token",
+		Scopes:      []string{oauth2.ScopeSellInventory, oauth2.ScopeSellFulfillment},
+	}
+
+	t.Run("HasGrantedScopes", func(t *testing.T) {
+		assert.True(t, token.HasScopes())
+		assert.True(t, token.HasScopes("sell.inventory"))
+		assert.True(t, token.HasScopes(oauth2.ScopeSellInventory, "sell.fulfillment"))
+		assert.False(t, token.HasScopes("sell.inventory", "sell.finances"))
+		assert.False(t, token.HasScopes("sell.inventroy"))
+	})
+
+	t.Run("ListsMissingScopes", func(t *testing.T) {
+		missing, err := token.MissingScopes([]string{"sell.inventory", "sell.finances", "sell.account"})
+		require.Nil(t, err, fmt.Sprintf("%v", err))
+
+		assert.Equal(t, []string{oauth2.ScopeSellFinances, oauth2.ScopeSellAccount}, missing)
+	})
+
+	t.Run("ErrorsOnUnknownScope", func(t *testing.T) {
+		_, err := token.MissingScopes([]string{"sell.inventroy"})
+
+		assert.True(t, errors.Is(err, oauth2.ErrUnknownScope), fmt.Sprintf("%v", err))
+	})
+}
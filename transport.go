@@ -0,0 +1,121 @@
+package oauth2
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Transport is an http.RoundTripper that authenticates requests to eBay's APIs with a bearer
+// token from Source.
+//
+// When a request is rejected with 401 Unauthorized and Source is a CachingTokenSource, the
+// cached token is invalidated and the request is retried once with a fresh token. Requests
+// with a body are only retried if the body can be rewound with http.Request.GetBody. If the fresh
+// token cannot be fetched, the 401 response is closed and the error of Source is returned.
+type Transport struct {
+	// Source supplies the tokens. It is required.
+	Source TokenSource
+	// Base is the RoundTripper used to make the requests. If nil, http.DefaultTransport is used.
+	Base http.RoundTripper
+}
+
+// NewHTTPClient returns an http.Client that authenticates its requests with tokens from source.
+func NewHTTPClient(source TokenSource) *http.Client {
+	return &http.Client{
+		Transport: &Transport{Source: source},
+	}
+}
+
+// RoundTrip authorizes and executes a single HTTP transaction, retrying once on 401 Unauthorized.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.Source.TokenWithContext(req.Context())
+	if err != nil {
+		closeRequestBody(req)
+		return nil, err
+	}
+
+	res, err := t.base().RoundTrip(authorizeRequest(req, token))
+	if err != nil || res.StatusCode != http.StatusUnauthorized {
+		return res, err
+	}
+
+	cts, ok := t.Source.(CachingTokenSource)
+	if !ok {
+		return res, nil
+	}
+
+	retryReq, ok := rewindRequest(req)
+	if !ok {
+		return res, nil
+	}
+
+	cts.Invalidate()
+
+	token, err = cts.TokenWithContext(req.Context())
+	if err != nil {
+		closeRequestBody(retryReq)
+		drainAndClose(res.Body)
+
+		return nil, fmt.Errorf("failed to refresh token after %s: %w", res.Status, err)
+	}
+
+	drainAndClose(res.Body)
+
+	return t.base().RoundTrip(authorizeRequest(retryReq, token))
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+
+	return http.DefaultTransport
+}
+
+// authorizeRequest returns a copy of req with the Authorization header set to the bearer token,
+// as a RoundTripper must not modify the request it is given.
+func authorizeRequest(req *http.Request, token *AccessToken) *http.Request {
+	authReq := req.Clone(req.Context())
+	authReq.Header.Set("Authorization", "Bearer "+token.AccessToken)
+
+	return authReq
+}
+
+// rewindRequest returns a copy of req with a fresh body, reporting false if the body cannot be rewound.
+func rewindRequest(req *http.Request) (*http.Request, bool) {
+	retryReq := req.Clone(req.Context())
+
+	if req.Body == nil || req.Body == http.NoBody {
+		return retryReq, true
+	}
+
+	if req.GetBody == nil {
+		return nil, false
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, false
+	}
+
+	retryReq.Body = body
+
+	return retryReq, true
+}
+
+func closeRequestBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+}
+
+// maxDrainBytes caps how much of an unread body is drained before it is closed. Larger
+// bodies are closed without draining, giving up on reusing their connection.
+const maxDrainBytes = 64 << 10
+
+// drainAndClose reads what is left of body so its connection can be reused, then closes it.
+func drainAndClose(body io.ReadCloser) {
+	_, _ = io.Copy(io.Discard, io.LimitReader(body, maxDrainBytes))
+	body.Close()
+}
@@ -0,0 +1,159 @@
+// +build unit
+
+package oauth2_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	oauth2 "github.com/ralucas/go-ebay-oauth2"
+)
+
+// sequenceFetcher issues "token-1", "token-2", ... on each call, or fails with err after the
+// first call when it is set.
+type sequenceFetcher struct {
+	err error
+
+	mu    sync.Mutex
+	count int
+}
+
+func (f *sequenceFetcher) AccessTokenWithContext(ctx context.Context) (*oauth2.AccessToken, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.count++
+
+	if f.err != nil && f.count > 1 {
+		return nil, f.err
+	}
+
+	return &oauth2.AccessToken{
+		AccessToken: fmt.Sprintf("token-%d", f.count),
+		ExpiresAt:   time.Now().Add(time.Hour),
+	}, nil
+}
+
+func TestTransport_RoundTrip(t *testing.T) {
+	t.Run("SetsBearerToken", func(t *testing.T) {
+		var authorization string
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authorization = r.Header.Get("Authorization")
+		}))
+		defer server.Close()
+
+		fetcher := &sequenceFetcher{}
+		client := oauth2.NewHTTPClient(oauth2.NewCachingTokenSource(fetcher))
+
+		req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+		require.Nil(t, err)
+
+		res, err := client.Do(req)
+		require.Nil(t, err)
+		res.Body.Close()
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "Bearer token-1", authorization)
+		assert.Empty(t, req.Header.Get("Authorization"))
+	})
+
+	t.Run("RetriesOnceWithFreshTokenOnUnauthorized", func(t *testing.T) {
+		var authorizations, bodies []string
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+
+			authorizations = append(authorizations, r.Header.Get("Authorization"))
+			bodies = append(bodies, string(body))
+
+			if r.Header.Get("Authorization") == "Bearer token-1" {
+				w.WriteHeader(http.StatusUnauthorized)
+			}
+		}))
+		defer server.Close()
+
+		fetcher := &sequenceFetcher{}
+		client := oauth2.NewHTTPClient(oauth2.NewCachingTokenSource(fetcher))
+
+		res, err := client.Post(server.URL, "application/json", strings.NewReader(`{"sku":"a"}`))
+		require.Nil(t, err)
+		res.Body.Close()
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, []string{"Bearer token-1", "Bearer token-2"}, authorizations)
+		assert.Equal(t, []string{`{"sku":"a"}`, `{"sku":"a"}`}, bodies)
+	})
+
+	t.Run("ReturnsSecondUnauthorized", func(t *testing.T) {
+		calls := 0
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.WriteHeader(http.StatusUnauthorized)
+		}))
+		defer server.Close()
+
+		fetcher := &sequenceFetcher{}
+		client := oauth2.NewHTTPClient(oauth2.NewCachingTokenSource(fetcher))
+
+		res, err := client.Get(server.URL)
+		require.Nil(t, err)
+		res.Body.Close()
+
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("ReturnsTokenErrorAfterUnauthorized", func(t *testing.T) {
+		calls := 0
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.WriteHeader(http.StatusUnauthorized)
+		}))
+		defer server.Close()
+
+		fetcher := &sequenceFetcher{err: oauth2.ErrInvalidGrant}
+		client := oauth2.NewHTTPClient(oauth2.NewCachingTokenSource(fetcher))
+
+		_, err := client.Get(server.URL)
+
+		assert.True(t, errors.Is(err, oauth2.ErrInvalidGrant), fmt.Sprintf("%v", err))
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("DoesNotRetryUnrewindableBody", func(t *testing.T) {
+		calls := 0
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.WriteHeader(http.StatusUnauthorized)
+		}))
+		defer server.Close()
+
+		fetcher := &sequenceFetcher{}
+		client := oauth2.NewHTTPClient(oauth2.NewCachingTokenSource(fetcher))
+
+		req, err := http.NewRequest(http.MethodPost, server.URL, io.NopCloser(strings.NewReader("body")))
+		require.Nil(t, err)
+
+		res, err := client.Do(req)
+		require.Nil(t, err)
+		res.Body.Close()
+
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+		assert.Equal(t, 1, calls)
+	})
+}